@@ -0,0 +1,319 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wtsi-hgi/statter/internal/client"
+)
+
+var (
+	// ErrMountUnresponsive is returned, wrapped in an *os.PathError, for any
+	// request made to a mount whose breaker is open.
+	ErrMountUnresponsive = errors.New("mount unresponsive")
+
+	// ErrTimeout will match a *StatterExitedError, with errors.Is, when the
+	// statter exited due to a request timing out.
+	ErrTimeout = client.ErrTimeout
+)
+
+type mountState struct {
+	timeouts int
+	open     bool
+}
+
+// MountBreaker wraps a statter, restarting it whenever it dies, and tracks the
+// number of consecutive timeouts seen for each mount. When the statter dies,
+// the *StatterExitedError is returned wrapped in an *os.PathError.
+//
+// Once a mount reaches the timeout threshold, its breaker opens and all
+// requests for paths under that mount will fail immediately with
+// ErrMountUnresponsive. While open, the mount will be probed in the background
+// after each cool-down period, and the breaker will be closed once the probe
+// succeeds.
+type MountBreaker struct {
+	exe       string
+	opts      *Options
+	threshold int
+	cooldown  time.Duration
+	sem       chan struct{}
+	done      chan struct{}
+
+	mu     sync.Mutex
+	mounts []string
+	conn   io.ReadWriteCloser
+	closed bool
+	states map[string]*mountState
+}
+
+// NewMountBreaker creates a MountBreaker that will run the statter at the
+// given path, spawned according to the given Options, opening the breaker for a
+// mount after threshold consecutive timeouts, and probing an open mount every
+// cooldown.
+func NewMountBreaker(exe string, opts *Options, threshold int, cooldown time.Duration) (*MountBreaker, error) {
+	mounts, err := client.ReadMounts(client.MountInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MountBreaker{
+		exe:       exe,
+		opts:      opts,
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		mounts:    mounts,
+		sem:       make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		states:    make(map[string]*mountState),
+	}, nil
+}
+
+// mountFor returns the mount point that contains the given path.
+func (m *MountBreaker) mountFor(path string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.findMount(path)
+}
+
+// findMount returns the mount point from the mount table that contains the
+// given path. Must be called with the lock held.
+func (m *MountBreaker) findMount(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "/"
+	}
+
+	for _, mount := range m.mounts {
+		if path == mount || strings.HasPrefix(path, strings.TrimSuffix(mount, "/")+"/") {
+			return mount
+		}
+	}
+
+	return "/"
+}
+
+// Stat performs the equivalent of an os.Lstat call using the statter.
+func (m *MountBreaker) Stat(path string) (fs.FileInfo, error) {
+	return do(m, "lstat", path, client.Stat)
+}
+
+// Head reads the first byte of a file using the statter.
+func (m *MountBreaker) Head(path string) (byte, error) {
+	return do(m, "read", path, client.Head)
+}
+
+// Readlink performs the equivalent of an os.Readlink call using the statter.
+func (m *MountBreaker) Readlink(path string) (string, error) {
+	return do(m, "readlink", path, client.Readlink)
+}
+
+func do[T any](m *MountBreaker, op, path string, fn func(io.ReadWriter, string) (T, error)) (T, error) {
+	var zero T
+
+	mount := m.mountFor(path)
+
+	if m.isOpen(mount) {
+		return zero, &os.PathError{Op: op, Path: path, Err: ErrMountUnresponsive}
+	}
+
+	m.sem <- struct{}{}
+	defer func() { <-m.sem }()
+
+	if m.isOpen(mount) {
+		return zero, &os.PathError{Op: op, Path: path, Err: ErrMountUnresponsive}
+	}
+
+	conn, err := m.connect()
+	if err != nil {
+		return zero, &os.PathError{Op: op, Path: path, Err: err}
+	}
+
+	v, err := fn(conn, path)
+	err = client.ExitError(conn, err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !errors.Is(err, io.EOF) {
+		m.state(mount).timeouts = 0
+
+		return v, err
+	}
+
+	conn.Close()
+
+	if m.conn == conn {
+		m.conn = nil
+	}
+
+	if errors.Is(err, ErrTimeout) {
+		mount = m.refreshMount(path, mount)
+
+		m.recordTimeout(mount, m.state(mount))
+	}
+
+	return zero, &os.PathError{Op: op, Path: path, Err: err}
+}
+
+// isOpen returns true if the breaker for the given mount is open.
+func (m *MountBreaker) isOpen(mount string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state(mount).open
+}
+
+// connect returns the running statter, starting a new one if needed, or
+// fs.ErrClosed if the MountBreaker has been closed.
+func (m *MountBreaker) connect() (io.ReadWriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, fs.ErrClosed
+	}
+
+	if m.conn == nil {
+		conn, _, err := client.CreateStatterWithOptions(m.exe, m.opts)
+		if err != nil {
+			return nil, err
+		}
+
+		m.conn = conn
+	}
+
+	return m.conn, nil
+}
+
+// refreshMount re-reads the mount table and returns the mount point that now
+// contains the given path, so that a timeout under a filesystem mounted since
+// the table was last read, such as by an automounter, isn't attributed to its
+// parent mount. Returns the given mount if the table can't be read. Must be
+// called with the lock held.
+func (m *MountBreaker) refreshMount(path, mount string) string {
+	mounts, err := client.ReadMounts(client.MountInfo)
+	if err != nil {
+		return mount
+	}
+
+	m.mounts = mounts
+
+	return m.findMount(path)
+}
+
+func (m *MountBreaker) state(mount string) *mountState {
+	state, ok := m.states[mount]
+	if !ok {
+		state = new(mountState)
+		m.states[mount] = state
+	}
+
+	return state
+}
+
+func (m *MountBreaker) recordTimeout(mount string, state *mountState) {
+	if state.open {
+		return
+	}
+
+	state.timeouts++
+
+	if state.timeouts < m.threshold {
+		return
+	}
+
+	state.open = true
+
+	go m.probe(mount, state)
+}
+
+// probe waits for the cool-down period and then attempts to stat the mount
+// point with a fresh statter, repeating until it succeeds, at which point the
+// breaker for the mount is closed. Probing stops once the MountBreaker is
+// closed.
+func (m *MountBreaker) probe(mount string, state *mountState) {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(m.cooldown):
+		}
+
+		if m.probeMount(mount) {
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state.open = false
+	state.timeouts = 0
+}
+
+func (m *MountBreaker) probeMount(mount string) bool {
+	conn, _, err := client.CreateStatterWithOptions(m.exe, m.opts)
+	if err != nil {
+		return false
+	}
+
+	defer conn.Close()
+
+	_, err = client.Stat(conn, mount)
+
+	return !errors.Is(err, io.EOF)
+}
+
+// Close stops the currently running statter, if any, along with any background
+// probing of open mounts. Requests made after Close return fs.ErrClosed.
+func (m *MountBreaker) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+
+		close(m.done)
+	}
+
+	if m.conn == nil {
+		return nil
+	}
+
+	err := m.conn.Close()
+	m.conn = nil
+
+	return err
+}
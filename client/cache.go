@@ -0,0 +1,240 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"container/list"
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheOptions configures a Cache.
+type CacheOptions struct {
+	// TTL is how long successful results are cached for.
+	TTL time.Duration
+
+	// NegativeTTL is how long results for paths that do not exist are cached
+	// for. A zero value disables negative caching.
+	NegativeTTL time.Duration
+
+	// MaxEntries is the maximum number of results that will be cached for each
+	// of Stat and Readlink, with the least recently used being evicted first. A
+	// zero value means no limit.
+	MaxEntries int
+}
+
+// CacheStats contains the counters for a Cache.
+type CacheStats struct {
+	// Hits is the number of requests answered from the cache.
+	Hits uint64
+
+	// Misses is the number of requests that were sent to the statter.
+	Misses uint64
+
+	// Shared is the number of requests that waited for the result of an
+	// identical request that was already being sent to the statter.
+	Shared uint64
+}
+
+// Cache sits in front of a Statter and a Readlinker, caching their results.
+//
+// Concurrent requests for the same path are collapsed into a single call to the
+// underlying function.
+type Cache struct {
+	stat     *cache[fs.FileInfo]
+	readlink *cache[string]
+
+	hits, misses, shared atomic.Uint64
+}
+
+// NewCache creates a Cache in front of the given Statter and Readlinker, either
+// of which may be nil if not required, in which case the matching method will
+// return an error wrapping fs.ErrInvalid.
+func NewCache(stat Statter, readlink Readlinker, opts CacheOptions) *Cache {
+	c := new(Cache)
+
+	c.stat = newCache(c, "lstat", stat, opts)
+	c.readlink = newCache(c, "readlink", readlink, opts)
+
+	return c
+}
+
+// Stat performs the equivalent of an os.Lstat call, returning a cached result
+// where possible.
+func (c *Cache) Stat(path string) (fs.FileInfo, error) {
+	return c.stat.get(path)
+}
+
+// Readlink performs the equivalent of an os.Readlink call, returning a cached
+// result where possible.
+func (c *Cache) Readlink(path string) (string, error) {
+	return c.readlink.get(path)
+}
+
+// Stats returns the current hit and miss counters.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Shared: c.shared.Load(),
+	}
+}
+
+var errPanicked = errors.New("request panicked")
+
+type result[T any] struct {
+	path    string
+	value   T
+	err     error
+	expires time.Time
+	done    chan struct{}
+}
+
+type cache[T any] struct {
+	parent *Cache
+	fn     func(string) (T, error)
+	opts   CacheOptions
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      list.List
+	inflight map[string]*result[T]
+}
+
+func newCache[T any](parent *Cache, op string, fn func(string) (T, error), opts CacheOptions) *cache[T] {
+	if fn == nil {
+		fn = func(path string) (T, error) {
+			var zero T
+
+			return zero, &fs.PathError{Op: op, Path: path, Err: fs.ErrInvalid}
+		}
+	}
+
+	return &cache[T]{
+		parent:   parent,
+		fn:       fn,
+		opts:     opts,
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*result[T]),
+	}
+}
+
+func (c *cache[T]) get(path string) (T, error) {
+	c.mu.Lock()
+
+	if r := c.cached(path); r != nil {
+		c.mu.Unlock()
+		c.parent.hits.Add(1)
+
+		return r.value, r.err
+	}
+
+	if r, ok := c.inflight[path]; ok {
+		c.mu.Unlock()
+		c.parent.shared.Add(1)
+
+		<-r.done
+
+		return r.value, r.err
+	}
+
+	r := &result[T]{path: path, err: errPanicked, done: make(chan struct{})}
+	c.inflight[path] = r
+
+	c.mu.Unlock()
+	c.parent.misses.Add(1)
+
+	defer c.store(r)
+
+	r.value, r.err = c.fn(path)
+
+	return r.value, r.err
+}
+
+// cached returns the unexpired cached result for the given path, if there is
+// one.
+func (c *cache[T]) cached(path string) *result[T] {
+	e, ok := c.entries[path]
+	if !ok {
+		return nil
+	}
+
+	r := e.Value.(*result[T]) //nolint:errcheck,forcetypeassert
+
+	if time.Now().After(r.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, path)
+
+		return nil
+	}
+
+	c.lru.MoveToFront(e)
+
+	return r
+}
+
+// store removes the given result from the inflight requests, caching it if
+// appropriate, and wakes anyone waiting for it. If the request panicked, the
+// waiters will see errPanicked.
+func (c *cache[T]) store(r *result[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer close(r.done)
+
+	delete(c.inflight, r.path)
+
+	ttl := c.ttl(r.err)
+	if ttl <= 0 {
+		return
+	}
+
+	r.expires = time.Now().Add(ttl)
+	c.entries[r.path] = c.lru.PushFront(r)
+
+	if c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+		oldest := c.lru.Back()
+
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*result[T]).path) //nolint:errcheck,forcetypeassert
+	}
+}
+
+// ttl returns how long a result with the given error should be cached for;
+// only successes and missing paths are cached.
+func (c *cache[T]) ttl(err error) time.Duration {
+	switch {
+	case err == nil:
+		return c.opts.TTL
+	case errors.Is(err, fs.ErrNotExist):
+		return c.opts.NegativeTTL
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,168 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCache(t *testing.T) {
+	Convey("With a cache in front of a Statter and Readlinker", t, func() {
+		var statCalls, readlinkCalls atomic.Uint64
+
+		release := make(chan struct{})
+		close(release)
+
+		stat := func(path string) (fs.FileInfo, error) {
+			statCalls.Add(1)
+
+			<-release
+
+			if path == "/missing" {
+				return nil, &os.PathError{Op: "lstat", Path: path, Err: syscall.ENOENT}
+			}
+
+			if path == "/denied" {
+				return nil, &os.PathError{Op: "lstat", Path: path, Err: syscall.EACCES}
+			}
+
+			return os.Lstat("/")
+		}
+
+		readlink := func(path string) (string, error) {
+			readlinkCalls.Add(1)
+
+			return path + "-target", nil
+		}
+
+		c := NewCache(stat, readlink, CacheOptions{
+			TTL:         time.Hour,
+			NegativeTTL: 100 * time.Millisecond,
+			MaxEntries:  2,
+		})
+
+		Convey("successful results are cached", func() {
+			_, err := c.Stat("/a")
+			So(err, ShouldBeNil)
+
+			_, err = c.Stat("/a")
+			So(err, ShouldBeNil)
+			So(statCalls.Load(), ShouldEqual, 1)
+
+			target, err := c.Readlink("/a")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, "/a-target")
+
+			target, err = c.Readlink("/a")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, "/a-target")
+			So(readlinkCalls.Load(), ShouldEqual, 1)
+
+			So(c.Stats(), ShouldResemble, CacheStats{Hits: 2, Misses: 2})
+		})
+
+		Convey("missing paths are cached for the negative TTL", func() {
+			_, err := c.Stat("/missing")
+			So(err, ShouldWrap, syscall.ENOENT)
+
+			_, err = c.Stat("/missing")
+			So(err, ShouldWrap, syscall.ENOENT)
+			So(statCalls.Load(), ShouldEqual, 1)
+
+			time.Sleep(150 * time.Millisecond)
+
+			_, err = c.Stat("/missing")
+			So(err, ShouldWrap, syscall.ENOENT)
+			So(statCalls.Load(), ShouldEqual, 2)
+		})
+
+		Convey("other errors are not cached", func() {
+			_, err := c.Stat("/denied")
+			So(err, ShouldWrap, syscall.EACCES)
+
+			_, err = c.Stat("/denied")
+			So(err, ShouldWrap, syscall.EACCES)
+			So(statCalls.Load(), ShouldEqual, 2)
+		})
+
+		Convey("the least recently used entries are evicted", func() {
+			for _, path := range []string{"/a", "/b", "/a", "/c", "/a", "/b"} {
+				_, err := c.Stat(path)
+				So(err, ShouldBeNil)
+			}
+
+			So(statCalls.Load(), ShouldEqual, 4)
+		})
+
+		Convey("concurrent requests for the same path are collapsed", func() {
+			release = make(chan struct{})
+
+			var wg sync.WaitGroup
+
+			for range 10 {
+				wg.Go(func() {
+					c.Stat("/a") //nolint:errcheck
+				})
+			}
+
+			for c.Stats().Shared != 9 {
+				time.Sleep(time.Millisecond)
+			}
+
+			close(release)
+			wg.Wait()
+
+			So(statCalls.Load(), ShouldEqual, 1)
+			So(c.Stats(), ShouldResemble, CacheStats{Misses: 1, Shared: 9})
+		})
+
+		Convey("a panicking request does not block later requests for the same path", func() {
+			c := NewCache(func(string) (fs.FileInfo, error) { panic("stat") }, nil, CacheOptions{TTL: time.Hour})
+
+			So(func() { c.Stat("/a") }, ShouldPanicWith, "stat") //nolint:errcheck
+			So(func() { c.Stat("/a") }, ShouldPanicWith, "stat") //nolint:errcheck
+			So(c.Stats(), ShouldResemble, CacheStats{Misses: 2})
+		})
+
+		Convey("a nil Statter or Readlinker returns an error", func() {
+			c := NewCache(nil, nil, CacheOptions{TTL: time.Hour})
+
+			_, err := c.Stat("/a")
+			So(err, ShouldWrap, fs.ErrInvalid)
+
+			_, err = c.Readlink("/a")
+			So(err, ShouldWrap, fs.ErrInvalid)
+		})
+	})
+}
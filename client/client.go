@@ -29,6 +29,7 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"iter"
 
 	"github.com/wtsi-hgi/statter/internal/client"
 )
@@ -37,6 +38,44 @@ type (
 	Statter    func(string) (fs.FileInfo, error)
 	Header     func(string) (byte, error)
 	Readlinker func(string) (string, error)
+
+	Options     = client.Options
+	WalkOptions = client.WalkOptions
+	Rlimits     = client.Rlimits
+	Patterns    = client.Patterns
+	WalkOrder   = client.WalkOrder
+	Usage       = client.Usage
+	DirUsage    = client.DirUsage
+	Paths       = client.Paths
+	Shard       = client.Shard
+	Entry       = client.Entry
+
+	StatterExitedError = client.StatterExitedError
+	WalkError          = client.WalkError
+)
+
+var (
+	// ErrInvalidPattern is returned when setting an invalid walk pattern.
+	ErrInvalidPattern = client.ErrInvalidPattern
+
+	// ErrInvalidOrder is returned when setting an unknown walk order, or when
+	// resuming a walk that isn't in lexical order.
+	ErrInvalidOrder = client.ErrInvalidOrder
+
+	// ErrWalk is wrapped by the errors passed to an ErrCallback for walk
+	// errors.
+	ErrWalk = client.ErrWalk
+)
+
+// DefaultAgeBuckets are the age buckets used when aggregating a walk without
+// WalkOptions.AgeBuckets being set.
+var DefaultAgeBuckets = client.DefaultAgeBuckets //nolint:gochecknoglobals
+
+// The orders in which the entries of a walk can be output.
+const (
+	OrderLexical      = client.OrderLexical
+	OrderBreadthFirst = client.OrderBreadthFirst
+	OrderDirsFirst    = client.OrderDirsFirst
 )
 
 // CreateStatter runs the statter at the given path, returning three functions
@@ -49,50 +88,185 @@ type (
 // The third function can be used to perform the equivalent of an os.Readlink
 // call.
 func CreateStatter(path string) (Statter, Header, Readlinker, error) {
-	local, _, err := client.CreateStatter(path)
+	return CreateStatterWithOptions(path, nil)
+}
+
+// CreateStatterWithOptions acts like CreateStatter, but spawns the statter
+// according to the given Options.
+func CreateStatterWithOptions(path string, opts *Options) (Statter, Header, Readlinker, error) {
+	c, err := Connect(path, opts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	return func(path string) (fs.FileInfo, error) {
-			return client.Stat(local, path)
-		}, func(path string) (byte, error) {
-			return client.Head(local, path)
-		}, func(path string) (string, error) {
-			return client.Readlink(local, path)
-		}, nil
+	return c.Stat, c.Head, c.Readlink, nil
 }
 
 type (
 	Dirent       = client.Dirent
 	PathCallback = client.PathCallback
 	ErrCallback  = client.ErrCallback
+	WalkHandlers = client.WalkHandlers
+	WalkProgress = client.WalkProgress
+	WalkSummary  = client.WalkSummary
 )
 
 // WalkPath runs the statter at the given exe path and performs a walk for the
-// given path.
+// given roots, in a single process.
+//
+// Roots are walked in lexical order, and any root that is the same as, or
+// within, another root is skipped.
 //
 // For each path entry, the PathCallback will be called with the directory entry
 // details.
 //
 // For each non-fatal error, such as permission issues, the ErrCallback will be
 // called with the failing path and the error.
-func WalkPath(exe, path string, cb PathCallback, errCB ErrCallback) error {
-	r, err := client.CreateWalker(exe, path)
+//
+// Once the walk has completed, a summary of its totals is returned.
+func WalkPath(exe string, roots []string, cb PathCallback, errCB ErrCallback) (*WalkSummary, error) {
+	return WalkPathWithOptions(exe, roots, nil, cb, errCB)
+}
+
+// WalkPathWithOptions acts like WalkPath, but spawns the statter according to
+// the given Options, calling the Options.Handlers for the control records of
+// the walk, such as the start and end of each root, and progress reports.
+func WalkPathWithOptions(exe string, roots []string, opts *Options, cb PathCallback,
+	errCB ErrCallback) (*WalkSummary, error) {
+	r, err := client.CreateWalkerWithOptions(exe, roots, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer r.Close()
 
+	var (
+		summary *WalkSummary
+		h       WalkHandlers
+	)
+
+	if opts != nil {
+		h = opts.Handlers
+	}
+
+	summaryCB := h.Summary
+	h.Summary = func(s *WalkSummary) error {
+		summary = s
+
+		if summaryCB == nil {
+			return nil
+		}
+
+		return summaryCB(s)
+	}
+
+	if err := readWalk(r, cb, errCB, &h); err != nil {
+		return nil, err
+	} else if summary == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return summary, nil
+}
+
+// PlanShards uses the statter at the given path, spawned according to the
+// given Options, to plan up to the given number of shards of the given roots,
+// each of which can be walked separately; see WalkOptions.Shards. Errors
+// reading directories are ignored, as they only affect the size estimates.
+//
+// The plan only reads the roots to the depth set by the MaxDepth walk option,
+// 3 by default; directories at that depth are sized by their direct entries,
+// and are never split, so deep trees may need a larger depth.
+func PlanShards(exe string, roots []string, shards int, opts *Options) ([]*Shard, error) {
+	if shards < 1 {
+		return nil, fs.ErrInvalid
+	}
+
+	var (
+		o       Options
+		planned []*Shard
+	)
+
+	if opts != nil {
+		o = *opts
+	}
+
+	o.Walk.Shards = shards
+	o.Handlers.Shard = func(s *Shard) error {
+		planned = append(planned, s)
+
+		return nil
+	}
+
+	if _, err := WalkPathWithOptions(exe, roots, &o, func(*Dirent) error { return nil },
+		func(string, error) error { return nil }); err != nil {
+		return nil, err
+	}
+
+	return planned, nil
+}
+
+func handlers(opts *Options) *WalkHandlers {
+	if opts == nil {
+		return nil
+	}
+
+	return &opts.Handlers
+}
+
+func readWalk(r io.ReadCloser, cb PathCallback, errCB ErrCallback, h *WalkHandlers) error {
 	for {
-		err := client.ReadDirEnt(r, cb, errCB)
+		err := client.ReadRecord(r, cb, errCB, h)
+		if errors.Is(err, io.EOF) {
+			return client.Exited(r)
+		} else if err != nil {
+			return client.ExitError(r, err)
+		}
+	}
+}
+
+var errStopped = errors.New("stopped")
+
+// Walk runs the statter at the given exe path and returns an iterator over the
+// directory entries of a walk of the given root.
+//
+// Non-fatal errors, such as permission issues, are yielded as a nil Dirent and
+// an *fs.PathError for the failing path, and iteration continues. A fatal
+// error is yielded with a nil Dirent as the last value.
+//
+// Breaking out of the iteration kills the walker.
+func Walk(exe, root string) iter.Seq2[*Dirent, error] {
+	return WalkWithOptions(exe, root, nil)
+}
+
+// WalkWithOptions acts like Walk, but spawns the statter according to the given
+// Options.
+func WalkWithOptions(exe, root string, opts *Options) iter.Seq2[*Dirent, error] {
+	return func(yield func(*Dirent, error) bool) {
+		r, err := client.CreateWalkerWithOptions(exe, []string{root}, opts)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
+			yield(nil, err)
+
+			return
+		}
+
+		defer r.Close()
+
+		err = readWalk(r, func(entry *Dirent) error {
+			if !yield(entry, nil) {
+				return errStopped
+			}
+
+			return nil
+		}, func(path string, err error) error {
+			if !yield(nil, &fs.PathError{Op: "walk", Path: path, Err: err}) {
+				return errStopped
 			}
 
-			return err
+			return nil
+		}, handlers(opts))
+		if err != nil && !errors.Is(err, errStopped) {
+			yield(nil, err)
 		}
 	}
 }
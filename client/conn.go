@@ -0,0 +1,141 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"io/fs"
+
+	"github.com/wtsi-hgi/statter/internal/client"
+)
+
+// Conn is a connection to a running statter process. It is safe for
+// concurrent use, though requests are handled by the statter one at a time.
+type Conn struct {
+	sem     chan struct{}
+	conn    io.ReadWriteCloser
+	pingSem chan struct{}
+	ping    io.ReadWriteCloser
+}
+
+// Connect runs the statter at the given path, spawned according to the given
+// Options, and returns a Conn that can be used to make requests to it.
+func Connect(exe string, opts *Options) (*Conn, error) {
+	conn, ping, err := client.CreateStatterWithPing(exe, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		sem:     make(chan struct{}, 1),
+		conn:    conn,
+		pingSem: make(chan struct{}, 1),
+		ping:    ping,
+	}, nil
+}
+
+// Stat performs the equivalent of an os.Lstat call using the statter.
+func (c *Conn) Stat(path string) (fs.FileInfo, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	v, err := client.Stat(c.conn, path)
+
+	return v, client.ExitError(c.conn, err)
+}
+
+// Head reads the first byte of a file using the statter.
+func (c *Conn) Head(path string) (byte, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	v, err := client.Head(c.conn, path)
+
+	return v, client.ExitError(c.conn, err)
+}
+
+// Readlink performs the equivalent of an os.Readlink call using the statter.
+func (c *Conn) Readlink(path string) (string, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	v, err := client.Readlink(c.conn, path)
+
+	return v, client.ExitError(c.conn, err)
+}
+
+// Readdir reads the entries of the directory at the given path using the
+// statter, returning them sorted by name.
+func (c *Conn) Readdir(path string) ([]Entry, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+
+	v, err := client.Readdir(c.conn, path)
+
+	return v, client.ExitError(c.conn, err)
+}
+
+// Ping sends a no-op request to the statter, which it answers without touching
+// the filesystem. A nil error shows that the statter itself is responsive.
+//
+// Pings are sent on their own connection, so do not wait for any outstanding
+// request; a successful ping while a request is stuck shows that only that
+// request is stuck, not the statter.
+//
+// If the context is cancelled before a response is received, the statter is
+// assumed to be stuck and is killed, and the context error is returned.
+func (c *Conn) Ping(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case c.pingSem <- struct{}{}:
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer func() { <-c.pingSem }()
+
+		errCh <- client.ExitError(c.conn, client.Ping(c.ping))
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		client.Kill(c.conn) //nolint:errcheck
+
+		return ctx.Err()
+	}
+}
+
+// Close stops the statter.
+func (c *Conn) Close() error {
+	c.ping.Close()
+
+	return c.conn.Close()
+}
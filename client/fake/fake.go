@@ -0,0 +1,579 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+// Package fake provides an in-process, in-memory stand-in for the statter,
+// with the same handle API as client.CreateStatter and client.WalkPath, that
+// allows delays, errors, timeouts and crashes to be injected for specific
+// paths.
+package fake
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wtsi-hgi/statter/client"
+	internalclient "github.com/wtsi-hgi/statter/internal/client"
+)
+
+const (
+	defaultTimeout = time.Second
+	hang           = time.Duration(1<<63 - 1)
+	timeoutStatus  = internalclient.ExitTimeout
+	crashStatus    = 2
+)
+
+var (
+	errTimeoutStatus = errors.New("exit status 3") //nolint:err113
+	errCrashStatus   = errors.New("exit status 2") //nolint:err113
+)
+
+type node struct {
+	stat   syscall.Stat_t
+	target string
+	data   []byte
+}
+
+type fault struct {
+	delay time.Duration
+	errno syscall.Errno
+	crash bool
+}
+
+// Statter is an in-memory filesystem tree that can be stat'd and walked as if
+// by a real statter.
+type Statter struct {
+	// Timeout is the stat timeout of the fake statter; any request delayed by
+	// at least this long will cause the statter to exit with client.ErrTimeout.
+	Timeout time.Duration
+
+	// DirTimeout is the maximum time to wait for each directory to be read
+	// during a walk, as with client.WalkOptions.DirTimeout; a read delayed by
+	// at least this long fails with ETIMEDOUT. A zero value, the default, has
+	// no timeout.
+	DirTimeout time.Duration
+
+	mu     sync.RWMutex
+	nodes  map[string]*node
+	faults map[string]fault
+	inode  uint64
+}
+
+// New returns a new Statter containing only an empty root directory.
+func New() *Statter {
+	s := &Statter{
+		Timeout: defaultTimeout,
+		nodes:   make(map[string]*node),
+		faults:  make(map[string]fault),
+	}
+
+	s.add("/", syscall.S_IFDIR|0o755, "", nil)
+
+	return s
+}
+
+// AddDir adds a directory, and any missing parents, to the tree.
+func (s *Statter) AddDir(p string) {
+	s.add(p, syscall.S_IFDIR|0o755, "", nil)
+}
+
+// AddFile adds a regular file with the given contents, and any missing parent
+// directories, to the tree.
+func (s *Statter) AddFile(p string, data []byte) {
+	s.add(p, syscall.S_IFREG|0o644, "", data)
+}
+
+// AddSymlink adds a symlink to the given target, and any missing parent
+// directories, to the tree.
+func (s *Statter) AddSymlink(p, target string) {
+	s.add(p, syscall.S_IFLNK|0o777, target, nil)
+}
+
+func (s *Statter) add(p string, mode uint32, target string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p = path.Clean(p)
+
+	for dir := path.Dir(p); s.nodes[dir] == nil; dir = path.Dir(dir) {
+		s.nodes[dir] = s.newNode(syscall.S_IFDIR|0o755, "", nil)
+	}
+
+	s.nodes[p] = s.newNode(mode, target, data)
+}
+
+func (s *Statter) newNode(mode uint32, target string, data []byte) *node {
+	s.inode++
+
+	size := int64(len(data))
+	if target != "" {
+		size = int64(len(target))
+	}
+
+	return &node{
+		stat: syscall.Stat_t{
+			Ino:   s.inode,
+			Mode:  mode,
+			Nlink: 1,
+			Size:  size,
+			Mtim:  syscall.Timespec{Sec: time.Now().Unix()},
+		},
+		target: target,
+		data:   data,
+	}
+}
+
+// Remove removes the given path, and anything beneath it, from the tree.
+func (s *Statter) Remove(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p = path.Clean(p)
+
+	for name := range s.nodes {
+		if name == p || strings.HasPrefix(name, strings.TrimSuffix(p, "/")+"/") {
+			delete(s.nodes, name)
+		}
+	}
+}
+
+// InjectDelay causes every request for the given path, including reading it
+// during a walk, to be delayed by the given duration.
+func (s *Statter) InjectDelay(p string, d time.Duration) {
+	s.setFault(p, func(f *fault) { f.delay = d })
+}
+
+// InjectErrno causes every request for the given path, including reading it
+// during a walk, to fail with the given error number.
+func (s *Statter) InjectErrno(p string, errno syscall.Errno) {
+	s.setFault(p, func(f *fault) { f.errno = errno })
+}
+
+// InjectTimeout causes every request for the given path to hang, so that the
+// statter exits with client.ErrTimeout. Reading the path as a directory during
+// a walk hangs, as with the real walk, unless the DirTimeout is set.
+func (s *Statter) InjectTimeout(p string) {
+	s.InjectDelay(p, hang)
+}
+
+// InjectCrash causes the statter to crash, as if it had panicked, when a
+// request is made for the given path, or when it is reached during a walk.
+func (s *Statter) InjectCrash(p string) {
+	s.setFault(p, func(f *fault) { f.crash = true })
+}
+
+// ClearFaults removes all injected faults for the given path.
+func (s *Statter) ClearFaults(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.faults, path.Clean(p))
+}
+
+func (s *Statter) setFault(p string, fn func(*fault)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p = path.Clean(p)
+	f := s.faults[p]
+
+	fn(&f)
+
+	s.faults[p] = f
+}
+
+// process holds the state of a single running fake statter.
+type process struct {
+	mu     sync.Mutex
+	exited error
+}
+
+func (p *process) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.exited
+}
+
+func (p *process) exit(err error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.exited == nil {
+		p.exited = err
+	}
+
+	return p.exited
+}
+
+// crash makes the process exit as if it had panicked while handling a request
+// for the given path.
+func (p *process) crash(name string) error {
+	return p.exit(&client.StatterExitedError{
+		ExitCode: crashStatus,
+		Stderr:   "panic: injected crash for " + name + "\n",
+		Err:      errCrashStatus,
+	})
+}
+
+// fault returns the faults injected for the given path.
+func (s *Statter) fault(name string) fault {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.faults[path.Clean(name)]
+}
+
+// request applies any faults for the given path and returns its node, or the
+// error number for the failure.
+func (s *Statter) request(p *process, timeout time.Duration, name string) (*node, error) {
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+
+	f := s.fault(name)
+
+	if f.crash {
+		return nil, p.crash(name)
+	}
+
+	if timeout > 0 && f.delay >= timeout {
+		time.Sleep(timeout)
+
+		return nil, p.exit(&client.StatterExitedError{
+			ExitCode: timeoutStatus,
+			Stderr:   client.ErrTimeout.Error() + "\n",
+			Err:      errTimeoutStatus,
+		})
+	}
+
+	time.Sleep(f.delay)
+
+	if f.errno != 0 {
+		return nil, f.errno
+	}
+
+	return s.lookup(name)
+}
+
+func (s *Statter) lookup(name string) (*node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if n, ok := s.nodes[path.Clean(name)]; ok {
+		return n, nil
+	}
+
+	return nil, syscall.ENOENT
+}
+
+// CreateStatter returns the same functions as client.CreateStatter, backed by
+// the in-memory tree.
+//
+// Each call creates a new fake statter process; once a request for a path
+// with an injected timeout or crash has been made, all further requests will
+// return the same *client.StatterExitedError.
+func (s *Statter) CreateStatter() (client.Statter, client.Header, client.Readlinker, error) {
+	p := new(process)
+	timeout := s.Timeout
+
+	return func(name string) (fs.FileInfo, error) {
+			n, err := s.request(p, timeout, name)
+			if err != nil {
+				return nil, pathError("lstat", name, err)
+			}
+
+			return internalclient.NewFileInfo(path.Base(name), &n.stat), nil
+		}, func(name string) (byte, error) {
+			n, err := s.request(p, timeout, name)
+			if err == nil && n.stat.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+				err = syscall.EISDIR
+			}
+
+			if err != nil {
+				return 0, pathError("read", name, err)
+			}
+
+			if len(n.data) == 0 {
+				return 0, nil
+			}
+
+			return n.data[0], nil
+		}, func(name string) (string, error) {
+			n, err := s.request(p, timeout, name)
+			if err == nil && n.target == "" {
+				err = syscall.EINVAL
+			}
+
+			if err != nil {
+				return "", pathError("readlink", name, err)
+			}
+
+			return n.target, nil
+		}, nil
+}
+
+func pathError(op, name string, err error) error {
+	var errno syscall.Errno
+
+	if !errors.As(err, &errno) {
+		return err
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: errno}
+}
+
+// WalkPath acts like client.WalkPath, walking the in-memory tree.
+//
+// Directory entries are passed to the PathCallback in the same order as the
+// real walk, and reading a directory with an injected error number will pass
+// that error to the ErrCallback. As with the real walk, the stat Timeout does
+// not apply; a directory read with an injected delay of at least DirTimeout, if
+// set, is passed to the ErrCallback with ETIMEDOUT, and otherwise waits for the
+// whole delay. As with the real walk, errors are passed to the ErrCallback as
+// a *client.WalkError, including for roots that can't be walked. An injected
+// crash for a directory, or for any other entry reached during the walk, will
+// end the walk with a *client.StatterExitedError.
+func (s *Statter) WalkPath(roots []string, cb client.PathCallback,
+	errCB client.ErrCallback) (*client.WalkSummary, error) {
+	if len(roots) == 0 {
+		return nil, fs.ErrInvalid
+	}
+
+	w := &walker{
+		s:          s,
+		p:          new(process),
+		dirTimeout: s.DirTimeout,
+		children:   s.index(),
+		summary:    &client.WalkSummary{Errnos: make(map[syscall.Errno]uint64)},
+		cb:         cb,
+		errCB:      errCB,
+	}
+	start := time.Now()
+
+	for _, root := range dedupRoots(roots) {
+		if err := w.walkRoot(root); err != nil {
+			return nil, err
+		}
+	}
+
+	w.summary.Elapsed = time.Since(start)
+
+	return w.summary, nil
+}
+
+// dedupRoots returns the given roots sorted, without any that are the same as,
+// or within, another root.
+func dedupRoots(roots []string) []string {
+	rootPath := func(root string) string {
+		return strings.TrimSuffix(path.Clean(root), "/") + "/"
+	}
+
+	roots = slices.Clone(roots)
+
+	slices.SortFunc(roots, func(a, b string) int {
+		return strings.Compare(rootPath(a), rootPath(b))
+	})
+
+	var last string
+
+	return slices.DeleteFunc(roots, func(root string) bool {
+		if last != "" && strings.HasPrefix(rootPath(root), last) {
+			return true
+		}
+
+		last = rootPath(root)
+
+		return false
+	})
+}
+
+// walker holds the state of a single fake walk.
+type walker struct {
+	s          *Statter
+	p          *process
+	dirTimeout time.Duration
+	children   map[string][]walkEntry
+	summary    *client.WalkSummary
+	cb         client.PathCallback
+	errCB      client.ErrCallback
+}
+
+// walkRoot walks the given root, passing it to the ErrCallback if it can't be
+// walked, as the real walk does.
+func (w *walker) walkRoot(root string) error {
+	rootPath := strings.TrimSuffix(path.Clean(root), "/") + "/"
+
+	if !strings.HasPrefix(root, "/") {
+		return w.error(rootPath, fs.ErrInvalid)
+	}
+
+	n, err := w.s.lookup(root)
+	if err != nil {
+		return w.error(rootPath, &os.PathError{Op: "stat", Path: root, Err: err})
+	} else if n.stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return w.error(rootPath, fs.ErrInvalid)
+	}
+
+	return w.walk(walkEntry{path: path.Clean(root), mode: fs.ModeDir, inode: n.stat.Ino})
+}
+
+// walk outputs the given directory and then walks its children, counting it as
+// walked once it is done, even if it couldn't be read, as the real walk does.
+func (w *walker) walk(dir walkEntry) error {
+	dirPath := strings.TrimSuffix(dir.path, "/") + "/"
+
+	if err := w.output(&client.Dirent{Path: dirPath, Mode: fs.ModeDir, Inode: dir.inode}); err != nil {
+		return err
+	}
+
+	if err := w.s.readDir(w.p, w.dirTimeout, dir.path); err != nil {
+		if exitErr := w.p.err(); exitErr != nil {
+			return exitErr
+		}
+
+		if err := w.error(dirPath, err); err != nil {
+			return err
+		}
+	} else {
+		for _, child := range w.children[dir.path] {
+			if err := w.walkChild(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.summary.Dirs++
+
+	return nil
+}
+
+func (w *walker) walkChild(child walkEntry) error {
+	if child.mode.IsDir() {
+		return w.walk(child)
+	}
+
+	if w.s.fault(child.path).crash {
+		return w.p.crash(child.path)
+	}
+
+	return w.output(&client.Dirent{Path: child.path, Mode: child.mode, Inode: child.inode})
+}
+
+func (w *walker) output(entry *client.Dirent) error {
+	w.summary.Entries++
+
+	return w.cb(entry)
+}
+
+// error counts the given error and passes it to the ErrCallback in the same
+// form as the real walk.
+func (w *walker) error(path string, err error) error {
+	var errno syscall.Errno
+
+	errors.As(err, &errno)
+
+	w.summary.Errors++
+
+	if errno != 0 {
+		w.summary.Errnos[errno]++
+	}
+
+	return w.errCB(path, &client.WalkError{Errno: errno, Msg: err.Error()})
+}
+
+// readDir applies any faults for reading the given directory during a walk,
+// and checks that it is still a directory. As with the real walk, a read that
+// takes at least the given timeout, if set, fails with ETIMEDOUT rather than
+// ending the walk.
+func (s *Statter) readDir(p *process, timeout time.Duration, dir string) error {
+	f := s.fault(dir)
+
+	if f.crash {
+		return p.crash(dir)
+	}
+
+	if timeout > 0 && f.delay >= timeout {
+		time.Sleep(timeout)
+
+		return syscall.ETIMEDOUT
+	}
+
+	time.Sleep(f.delay)
+
+	if f.errno != 0 {
+		return f.errno
+	}
+
+	n, err := s.lookup(dir)
+	if err == nil && n.stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		err = syscall.ENOTDIR
+	}
+
+	return err
+}
+
+type walkEntry struct {
+	path  string
+	sort  string
+	mode  fs.FileMode
+	inode uint64
+}
+
+// index returns the entries of the tree keyed by the path of their parent
+// directory, each sorted as the real walk would sort them.
+func (s *Statter) index() map[string][]walkEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	children := make(map[string][]walkEntry)
+
+	for name, n := range s.nodes {
+		if name == "/" {
+			continue
+		}
+
+		mode := internalclient.NewFileInfo(name, &n.stat).Mode().Type()
+		e := walkEntry{path: name, sort: name, mode: mode, inode: n.stat.Ino}
+
+		if e.mode.IsDir() {
+			e.sort += "/"
+		}
+
+		children[path.Dir(name)] = append(children[path.Dir(name)], e)
+	}
+
+	for _, entries := range children {
+		slices.SortFunc(entries, func(a, b walkEntry) int { return strings.Compare(a.sort, b.sort) })
+	}
+
+	return children
+}
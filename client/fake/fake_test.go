@@ -0,0 +1,258 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/wtsi-hgi/statter/client"
+)
+
+func walkErr(_ *client.WalkSummary, err error) error {
+	return err
+}
+
+func TestFake(t *testing.T) {
+	Convey("With a fake statter containing a tree", t, func() {
+		s := New()
+		s.Timeout = 100 * time.Millisecond
+
+		s.AddFile("/a/b/file", []byte("data"))
+		s.AddFile("/a/empty", nil)
+		s.AddSymlink("/a/link", "b/file")
+		s.AddDir("/a/c")
+
+		stat, head, readlink, err := s.CreateStatter()
+		So(err, ShouldBeNil)
+
+		Convey("you can stat, head and readlink paths", func() {
+			fi, err := stat("/a/b/file")
+			So(err, ShouldBeNil)
+			So(fi.Name(), ShouldEqual, "file")
+			So(fi.Size(), ShouldEqual, 4)
+			So(fi.Mode(), ShouldEqual, fs.FileMode(0o644))
+
+			fi, err = stat("/a/b")
+			So(err, ShouldBeNil)
+			So(fi.IsDir(), ShouldBeTrue)
+
+			_, err = stat("/missing")
+			So(err.Error(), ShouldEqual, "lstat /missing: no such file or directory")
+
+			b, err := head("/a/b/file")
+			So(err, ShouldBeNil)
+			So(b, ShouldEqual, 'd')
+
+			b, err = head("/a/empty")
+			So(err, ShouldBeNil)
+			So(b, ShouldEqual, 0)
+
+			_, err = head("/a")
+			So(err.Error(), ShouldEqual, "read /a: is a directory")
+
+			target, err := readlink("/a/link")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, "b/file")
+
+			_, err = readlink("/a/empty")
+			So(err.Error(), ShouldEqual, "readlink /a/empty: invalid argument")
+		})
+
+		Convey("you can inject delays and errors", func() {
+			s.InjectDelay("/a/b/file", 20*time.Millisecond)
+			s.InjectErrno("/a/empty", syscall.EACCES)
+
+			start := time.Now()
+
+			_, err := stat("/a/b/file")
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+
+			_, err = stat("/a/empty")
+			So(err.Error(), ShouldEqual, "lstat /a/empty: permission denied")
+
+			s.ClearFaults("/a/empty")
+
+			_, err = stat("/a/empty")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("an injected timeout kills the statter", func() {
+			s.InjectTimeout("/a/b/file")
+
+			_, err := stat("/a/b/file")
+			So(errors.Is(err, client.ErrTimeout), ShouldBeTrue)
+			So(errors.Is(err, io.EOF), ShouldBeTrue)
+
+			_, err = stat("/a")
+			So(errors.Is(err, client.ErrTimeout), ShouldBeTrue)
+
+			stat, _, _, err = s.CreateStatter()
+			So(err, ShouldBeNil)
+
+			_, err = stat("/a")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("an injected crash kills the statter", func() {
+			s.InjectCrash("/a/link")
+
+			_, err := readlink("/a/link")
+
+			var exitErr *client.StatterExitedError
+
+			So(errors.As(err, &exitErr), ShouldBeTrue)
+			So(exitErr.Panicked(), ShouldBeTrue)
+
+			_, err = head("/a/b/file")
+			So(errors.As(err, &exitErr), ShouldBeTrue)
+		})
+
+		Convey("you can walk the tree", func() {
+			var (
+				paths []string
+				errs  []string
+			)
+
+			cb := func(entry *client.Dirent) error {
+				paths = append(paths, entry.Path)
+
+				return nil
+			}
+
+			errCB := func(path string, err error) error {
+				errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+
+				return nil
+			}
+
+			s.InjectErrno("/a/b", syscall.EACCES)
+
+			summary, err := s.WalkPath([]string{"/a"}, cb, errCB)
+			So(err, ShouldBeNil)
+			So(summary.Dirs, ShouldEqual, 3)
+			So(summary.Entries, ShouldEqual, 5)
+			So(summary.Errors, ShouldEqual, 1)
+			So(summary.Errnos, ShouldResemble, map[syscall.Errno]uint64{syscall.EACCES: 1})
+			So(paths, ShouldResemble, []string{"/a/", "/a/b/", "/a/c/", "/a/empty", "/a/link"})
+			So(errs, ShouldResemble, []string{"/a/b/: permission denied"})
+
+			s.ClearFaults("/a/b")
+			s.InjectDelay("/a/b", 200*time.Millisecond)
+
+			paths, errs = nil, nil
+
+			So(walkErr(s.WalkPath([]string{"/a"}, cb, errCB)), ShouldBeNil)
+			So(errs, ShouldBeEmpty)
+
+			s.InjectTimeout("/a/b")
+			s.DirTimeout = 100 * time.Millisecond
+
+			paths, errs = nil, nil
+
+			summary, err = s.WalkPath([]string{"/a"}, cb, errCB)
+			So(err, ShouldBeNil)
+			So(summary.Dirs, ShouldEqual, 3)
+			So(summary.Errnos, ShouldResemble, map[syscall.Errno]uint64{syscall.ETIMEDOUT: 1})
+			So(paths, ShouldResemble, []string{"/a/", "/a/b/", "/a/c/", "/a/empty", "/a/link"})
+			So(errs, ShouldResemble, []string{"/a/b/: connection timed out"})
+
+			s.InjectCrash("/a/c")
+
+			_, err = s.WalkPath([]string{"/a"}, cb, errCB)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "statter exited: exit status 2: panic: injected crash for /a/c")
+
+			s.ClearFaults("/a/c")
+			s.InjectCrash("/a/empty")
+
+			_, err = s.WalkPath([]string{"/a"}, cb, errCB)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "statter exited: exit status 2: panic: injected crash for /a/empty")
+
+			s.ClearFaults("/a/empty")
+			s.ClearFaults("/a/b")
+
+			paths = nil
+
+			So(walkErr(s.WalkPath([]string{"/a/c", "/a/b/", "/a/b/file"}, cb, errCB)), ShouldBeNil)
+			So(paths, ShouldResemble, []string{"/a/b/", "/a/b/file", "/a/c/"})
+
+			So(walkErr(s.WalkPath(nil, cb, errCB)), ShouldEqual, fs.ErrInvalid)
+
+			errs = nil
+
+			var rootErrs []error
+
+			So(walkErr(s.WalkPath([]string{"a", "/a/empty", "/missing"}, cb, func(path string, err error) error {
+				rootErrs = append(rootErrs, err)
+
+				return errCB(path, err)
+			})), ShouldBeNil)
+			So(errs, ShouldResemble, []string{
+				"/a/empty/: invalid argument",
+				"/missing/: stat /missing: no such file or directory",
+				"a/: invalid argument",
+			})
+			So(errors.Is(rootErrs[0], client.ErrWalk), ShouldBeTrue)
+			So(errors.Is(rootErrs[0], fs.ErrInvalid), ShouldBeFalse)
+			So(errors.Is(rootErrs[1], client.ErrWalk), ShouldBeTrue)
+			So(errors.Is(rootErrs[1], syscall.ENOENT), ShouldBeTrue)
+		})
+
+		Convey("a directory removed during a walk is reported as an error", func() {
+			var (
+				paths []string
+				errs  []string
+			)
+
+			summary, err := s.WalkPath([]string{"/a"}, func(entry *client.Dirent) error {
+				paths = append(paths, entry.Path)
+
+				if entry.Path == "/a/b/" {
+					s.Remove("/a/b")
+				}
+
+				return nil
+			}, func(path string, err error) error {
+				errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(summary.Dirs, ShouldEqual, 3)
+			So(paths, ShouldResemble, []string{"/a/", "/a/b/", "/a/c/", "/a/empty", "/a/link"})
+			So(errs, ShouldResemble, []string{"/a/b/: no such file or directory"})
+		})
+	})
+}
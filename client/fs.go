@@ -0,0 +1,266 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+)
+
+const maxSymlinks = 40
+
+// FS is an fs.FS, rooted at a directory, that routes every operation through a
+// statter.
+//
+// In addition to fs.FS, it implements fs.StatFS, fs.ReadDirFS and
+// fs.ReadLinkFS. Files can be opened in order to Stat them, or ReadDir them
+// in the case of directories, but the statter does not provide access to file
+// contents, so reading from a file will return an error.
+type FS struct {
+	conn *Conn
+	root string
+}
+
+var (
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadLinkFS = (*FS)(nil)
+)
+
+// NewFS returns an FS rooted at the given directory, that uses the given Conn
+// for all operations.
+func NewFS(c *Conn, root string) *FS {
+	return &FS{conn: c, root: root}
+}
+
+func (f *FS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return filepath.Join(f.root, filepath.FromSlash(name)), nil
+}
+
+// Open opens the named file or directory, following symlinks.
+func (f *FS) Open(name string) (fs.File, error) {
+	fi, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{fsys: f, name: name, info: fi}, nil
+}
+
+// Stat returns the FileInfo for the named file, following symlinks.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *FS) stat(op, name string) (fs.FileInfo, error) {
+	path, err := f.path(op, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.follow(path)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+
+	return fi, nil
+}
+
+// follow stats the given path, following any symlinks.
+func (f *FS) follow(path string) (fs.FileInfo, error) {
+	name := filepath.Base(path)
+
+	for range maxSymlinks {
+		fi, err := f.conn.Stat(path)
+		if err != nil {
+			return nil, err
+		} else if fi.Mode()&fs.ModeSymlink == 0 {
+			return namedFileInfo{FileInfo: fi, name: name}, nil
+		}
+
+		target, err := f.conn.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		path = target
+	}
+
+	return nil, syscall.ELOOP
+}
+
+type namedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (n namedFileInfo) Name() string { return n.name }
+
+// Lstat returns the FileInfo for the named file, without following symlinks.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	path, err := f.path("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.conn.Stat(path)
+	if err != nil {
+		return nil, pathError("lstat", name, err)
+	}
+
+	return fi, nil
+}
+
+// ReadLink returns the target of the named symlink.
+func (f *FS) ReadLink(name string) (string, error) {
+	path, err := f.path("readlink", name)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := f.conn.Readlink(path)
+	if err != nil {
+		return "", pathError("readlink", name, err)
+	}
+
+	return target, nil
+}
+
+// ReadDir reads the named directory, returning its entries sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := f.conn.Readdir(path)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	dirEntries := make([]fs.DirEntry, len(entries))
+
+	for n, entry := range entries {
+		dirEntries[n] = &dirEntry{fsys: f, dir: name, entry: entry}
+	}
+
+	return dirEntries, nil
+}
+
+// pathError replaces the path in any *fs.PathError with the given name, so
+// that errors refer to names within the FS.
+func pathError(op, name string, err error) error {
+	var perr *fs.PathError
+
+	if errors.As(err, &perr) {
+		err = perr.Err
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+type dirEntry struct {
+	fsys  *FS
+	dir   string
+	entry Entry
+}
+
+func (d *dirEntry) Name() string      { return d.entry.Name }
+func (d *dirEntry) IsDir() bool       { return d.entry.Type.IsDir() }
+func (d *dirEntry) Type() fs.FileMode { return d.entry.Type }
+func (d *dirEntry) String() string    { return fs.FormatDirEntry(d) }
+
+func (d *dirEntry) Info() (fs.FileInfo, error) {
+	return d.fsys.Lstat(pathJoin(d.dir, d.entry.Name))
+}
+
+func pathJoin(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+
+	return dir + "/" + name
+}
+
+type file struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+func (f *file) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+// ReadDir reads the contents of the directory, as per fs.ReadDirFile.
+func (f *file) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+	}
+
+	if !f.read {
+		entries, err := f.fsys.ReadDir(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		f.entries = entries
+		f.read = true
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
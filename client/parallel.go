@@ -0,0 +1,454 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/wtsi-hgi/statter/internal/client"
+)
+
+// parallelDepth is the number of levels each statter of a parallel walk reads
+// from the directories it is given, before handing the subdirectories beyond
+// that back to be walked by the next free worker.
+const parallelDepth = 2
+
+var errParallelStopped = errors.New("parallel walk stopped")
+
+// parallelUnit is a directory to be walked by one of the workers of a parallel
+// walk, along with its depth from the original root.
+type parallelUnit struct {
+	path  string
+	depth int
+}
+
+// streamKey identifies the walk options of a stream, which are the same for
+// every unit other than those near the maximum depth.
+type streamKey struct {
+	maxDepth int
+	frontier bool
+}
+
+// parallelStream is a statter kept running by a worker of a parallel walk,
+// which walks each directory written to it in turn.
+type parallelStream struct {
+	roots    io.WriteCloser
+	r        io.ReadCloser
+	h        WalkHandlers
+	frontier int
+	done     bool
+	summary  *WalkSummary
+}
+
+type parallelWalk struct {
+	exe     string
+	opts    Options
+	workers int
+	cb      PathCallback
+	errCB   ErrCallback
+	start   time.Time
+
+	cbMu    sync.Mutex
+	stopped atomic.Bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []parallelUnit
+	active  int
+	err     error
+	summary WalkSummary
+}
+
+// ParallelWalk acts like WalkPathWithOptions, but spreads the walk of the given
+// roots across the given number of workers, each with its own statter. The
+// roots are read a few levels deep by the first free worker, which hands back
+// the subdirectories beyond those to be given to whichever worker is next free.
+// Each worker keeps its statter running, see WalkOptions.Stream, reading each
+// directory it is given a few levels deep in turn, and handing back the
+// subdirectories beyond those in the same way. The entries, errors, and
+// directory complete and usage records of all of the statters are passed to
+// the callbacks and handlers, which are not called concurrently.
+//
+// Entries are not output in any particular order, and each directory is
+// completed once the entries read by its statter have been output. The Order,
+// CheckpointInterval, and ProgressInterval options, and the RootStart, RootEnd,
+// Checkpoint, and Progress handlers, are not used, and hardlinks are only
+// tracked within each statter. A directory that can no longer be walked when
+// it is handed out, such as one removed since it was found, is passed to errCB
+// and the rest of the walk carries on.
+//
+// Returns fs.ErrInvalid if there are no roots, if workers is less than 1, or if
+// Resume or Shards are set, or FollowSymlinks, as the directories already seen
+// through symlinks can't be shared between statters.
+func ParallelWalk(exe string, roots []string, workers int, opts *Options, cb PathCallback,
+	errCB ErrCallback) (*WalkSummary, error) {
+	p := &parallelWalk{exe: exe, workers: workers, cb: cb, errCB: errCB, start: time.Now()}
+
+	if opts != nil {
+		p.opts = *opts
+	}
+
+	if workers < 1 || len(roots) == 0 || p.opts.Walk.Resume != "" || p.opts.Walk.Shards > 0 ||
+		p.opts.Walk.FollowSymlinks {
+		return nil, fs.ErrInvalid
+	}
+
+	p.cond = sync.NewCond(&p.mu)
+	p.summary.Errnos = make(map[syscall.Errno]uint64)
+
+	for _, root := range roots {
+		p.queue = append(p.queue, parallelUnit{path: root})
+	}
+
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Go(p.worker)
+	}
+
+	wg.Wait()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	p.summary.Elapsed = time.Since(p.start)
+
+	if p.opts.Handlers.Summary != nil {
+		if err := p.opts.Handlers.Summary(&p.summary); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p.summary, nil
+}
+
+// worker walks units from the queue until there are none left and no other
+// worker is running a walk that could add more, and then ends the walks of its
+// streams.
+func (p *parallelWalk) worker() {
+	streams := make(map[streamKey]*parallelStream)
+
+	defer p.closeStreams(streams)
+
+	for {
+		units := p.next()
+		if units == nil {
+			return
+		}
+
+		if units[0].depth == 0 {
+			p.finish(p.walk(units))
+		} else {
+			p.finish(nil, p.walkStream(streams, units))
+		}
+	}
+}
+
+// next waits for units to be available and takes a share of those at the same
+// depth as the first, or returns nil if the walk is over.
+func (p *parallelWalk) next() []parallelUnit {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.queue) == 0 && p.active > 0 && p.err == nil {
+		p.cond.Wait()
+	}
+
+	if len(p.queue) == 0 || p.err != nil {
+		return nil
+	}
+
+	// The original roots are walked together, so that any roots within other
+	// roots are skipped.
+	depth := p.queue[0].depth
+	limit := len(p.queue)
+
+	if depth > 0 {
+		limit = max(1, len(p.queue)/p.workers)
+	}
+
+	n := 1
+
+	for n < limit && p.queue[n].depth == depth {
+		n++
+	}
+
+	units := p.queue[:n:n]
+	p.queue = p.queue[n:]
+	p.active++
+
+	return units
+}
+
+// push adds a unit to the queue for the next free worker.
+func (p *parallelWalk) push(unit parallelUnit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queue = append(p.queue, unit)
+
+	p.cond.Signal()
+}
+
+// finish records the result of walking a share of the units.
+func (p *parallelWalk) finish(summary *WalkSummary, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.active--
+
+	p.record(summary, err)
+	p.cond.Broadcast()
+}
+
+// record adds the given summary, if any, to the totals, or records the error,
+// stopping the other walks. Must be called with the lock held.
+func (p *parallelWalk) record(summary *WalkSummary, err error) {
+	if err != nil {
+		if p.err == nil {
+			p.err = err
+		}
+
+		p.stopped.Store(true)
+
+		return
+	}
+
+	if summary == nil {
+		return
+	}
+
+	p.summary.Dirs += summary.Dirs
+	p.summary.Entries += summary.Entries
+	p.summary.Errors += summary.Errors
+	p.summary.Bytes += summary.Bytes
+
+	for errno, count := range summary.Errnos {
+		p.summary.Errnos[errno] += count
+	}
+}
+
+// unitOptions returns the options used to walk units at the given depth, which
+// are limited so that the subdirectories beyond parallelDepth are reported as
+// the frontier, to be added to the queue. The root entries of units that were
+// handed back are skipped, as they were already output by the walk that found
+// them.
+func (p *parallelWalk) unitOptions(depth int) *Options {
+	opts := p.opts
+	opts.Walk.MaxDepth = parallelDepth
+	opts.Walk.Order = OrderLexical
+	opts.Walk.CheckpointInterval = 0
+	opts.Walk.ProgressInterval = 0
+
+	if maxDepth := p.opts.Walk.MaxDepth; maxDepth > 0 {
+		opts.Walk.MaxDepth = min(parallelDepth, maxDepth-depth)
+	}
+
+	opts.Walk.Frontier = p.opts.Walk.MaxDepth == 0 || depth+opts.Walk.MaxDepth < p.opts.Walk.MaxDepth
+	opts.Walk.SkipRoots = depth > 0
+	opts.Handlers = WalkHandlers{}
+
+	if fn := p.opts.Handlers.DirComplete; fn != nil {
+		opts.Handlers.DirComplete = func(path string, entries uint64) error {
+			return p.serialise(func() error { return fn(path, entries) })
+		}
+	}
+
+	if fn := p.opts.Handlers.Usage; fn != nil {
+		opts.Handlers.Usage = func(usage *DirUsage) error {
+			return p.serialise(func() error { return fn(usage) })
+		}
+	}
+
+	return &opts
+}
+
+// walk runs a statter to walk the given units, which all have the same depth.
+func (p *parallelWalk) walk(units []parallelUnit) (*WalkSummary, error) {
+	depth := units[0].depth
+	opts := p.unitOptions(depth)
+	opts.Handlers.Frontier = func(path string) error {
+		p.push(parallelUnit{path: path, depth: depth + opts.Walk.MaxDepth})
+
+		return nil
+	}
+
+	roots := make([]string, len(units))
+
+	for n, unit := range units {
+		roots[n] = unit.path
+	}
+
+	return WalkPathWithOptions(p.exe, roots, opts, p.entry, p.error)
+}
+
+// walkStream walks the given units, which all have the same depth, one at a
+// time, using the worker's stream for their walk options, which is started if
+// the worker doesn't have one yet. Units with paths that can't be written as a
+// single line are instead walked by a statter of their own.
+func (p *parallelWalk) walkStream(streams map[streamKey]*parallelStream, units []parallelUnit) error {
+	opts := p.unitOptions(units[0].depth)
+	key := streamKey{maxDepth: opts.Walk.MaxDepth, frontier: opts.Walk.Frontier}
+
+	s, ok := streams[key]
+	if !ok {
+		var err error
+
+		if s, err = p.startStream(opts); err != nil {
+			return err
+		}
+
+		streams[key] = s
+	}
+
+	s.frontier = units[0].depth + opts.Walk.MaxDepth
+
+	for _, unit := range units {
+		if strings.Contains(unit.path, "\n") {
+			summary, err := p.walk([]parallelUnit{unit})
+			if err != nil {
+				return err
+			}
+
+			p.mu.Lock()
+			p.record(summary, nil)
+			p.mu.Unlock()
+
+			continue
+		}
+
+		if err := p.walkUnit(s, unit); err != nil {
+			delete(streams, key)
+			s.r.Close()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startStream starts a statter that walks each directory written to it, using
+// the given options.
+func (p *parallelWalk) startStream(opts *Options) (*parallelStream, error) {
+	roots, r, err := client.CreateStreamWalker(p.exe, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &parallelStream{roots: roots, r: r, h: opts.Handlers}
+	s.h.Frontier = func(path string) error {
+		p.push(parallelUnit{path: path, depth: s.frontier})
+
+		return nil
+	}
+	s.h.RootEnd = func(string) error {
+		s.done = true
+
+		return nil
+	}
+	s.h.Summary = func(summary *WalkSummary) error {
+		s.summary = summary
+
+		return nil
+	}
+
+	return s, nil
+}
+
+// walkUnit writes the given unit to the stream, and reads the records of the
+// stream until the walk of the unit has ended.
+func (p *parallelWalk) walkUnit(s *parallelStream, unit parallelUnit) error {
+	if _, err := io.WriteString(s.roots, unit.path+"\n"); err != nil {
+		if exitErr := client.Exited(s.r); exitErr != nil {
+			return exitErr
+		}
+
+		return err
+	}
+
+	for s.done = false; !s.done; {
+		if err := client.ReadRecord(s.r, p.entry, p.error, &s.h); err != nil {
+			return client.ExitError(s.r, err)
+		}
+	}
+
+	return nil
+}
+
+// closeStreams ends the walks of the given streams, adding their summaries to
+// the totals, or kills them if the parallel walk has been stopped.
+func (p *parallelWalk) closeStreams(streams map[streamKey]*parallelStream) {
+	for _, s := range streams {
+		if p.stopped.Load() {
+			s.r.Close()
+
+			continue
+		}
+
+		s.roots.Close()
+
+		err := readWalk(s.r, p.entry, p.error, &s.h)
+		if err == nil && s.summary == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		s.r.Close()
+
+		p.mu.Lock()
+		p.record(s.summary, err)
+		p.mu.Unlock()
+	}
+}
+
+func (p *parallelWalk) entry(entry *Dirent) error {
+	return p.serialise(func() error { return p.cb(entry) })
+}
+
+func (p *parallelWalk) error(path string, err error) error {
+	return p.serialise(func() error { return p.errCB(path, err) })
+}
+
+// serialise calls the given function with the callback lock held, unless the
+// walk has been stopped.
+func (p *parallelWalk) serialise(fn func() error) error {
+	if p.stopped.Load() {
+		return errParallelStopped
+	}
+
+	p.cbMu.Lock()
+	defer p.cbMu.Unlock()
+
+	return fn()
+}
@@ -2,10 +2,7 @@ module github.com/wtsi-hgi/statter
 
 go 1.25.5
 
-require (
-	github.com/smartystreets/goconvey v1.8.1
-	github.com/wtsi-hgi/walk v1.0.1
-)
+require github.com/smartystreets/goconvey v1.8.1
 
 require (
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
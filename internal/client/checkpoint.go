@@ -0,0 +1,82 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"strings"
+	"time"
+)
+
+// Entries are output in the lexical order of their full paths, so a
+// checkpoint is the path of a directory whose walk has completed; it, every
+// path within it, and every path that sorts before it, have been output.
+
+// resumeDone returns true if the given path was output before the given
+// checkpoint was made.
+func resumeDone(checkpoint, path string) bool {
+	if checkpoint == "" {
+		return false
+	}
+
+	return path <= checkpoint || strings.HasSuffix(checkpoint, "/") && strings.HasPrefix(path, checkpoint)
+}
+
+// resumeSkip returns true if the directory at the given path, and everything
+// within it, was output before the given checkpoint was made, so that the
+// directory doesn't need to be read.
+func resumeSkip(checkpoint, dirPath string) bool {
+	return resumeDone(checkpoint, dirPath) && (dirPath == checkpoint || !strings.HasPrefix(checkpoint, dirPath))
+}
+
+// checkpointer emits checkpoints for completed directories, no more often than
+// the checkpoint interval.
+type checkpointer struct {
+	interval time.Duration
+	last     time.Time
+	ctrl     func(kind uint32, payload string) error
+}
+
+func newCheckpointer(opts *WalkOptions, ctrl func(kind uint32, payload string) error) *checkpointer {
+	c := &checkpointer{last: time.Now(), ctrl: ctrl}
+
+	if opts != nil && ctrl != nil && opts.Order == OrderLexical {
+		c.interval = opts.CheckpointInterval
+	}
+
+	return c
+}
+
+// completed records that the directory at the given path has been fully output,
+// emitting a checkpoint for it if the interval has passed since the last one.
+func (c *checkpointer) completed(dirPath string) error {
+	if c.interval <= 0 || time.Since(c.last) < c.interval {
+		return nil
+	}
+
+	c.last = time.Now()
+
+	return c.ctrl(controlCheckpoint, dirPath)
+}
@@ -0,0 +1,767 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	direntInodeStart  = 0
+	direntReclenStart = 16
+	direntTypeStart   = 18
+	direntNameStart   = 19
+
+	// maxDirentSize is the size of a dirent with a 255 byte name, aligned to 8
+	// bytes; a smaller read buffer could fail to read some directories.
+	maxDirentSize = 280
+
+	defaultWalkers = 16
+
+	// aheadPerWalker is the number of directories, per reader, that can be
+	// read but not yet output, bounding how far the readers can get ahead of
+	// the output.
+	aheadPerWalker = 4
+)
+
+var (
+	scan     = scanDir //nolint:gochecknoglobals
+	statRoot = os.Stat //nolint:gochecknoglobals
+)
+
+// entry is a single entry read from a directory. Directory names end with a
+// slash.
+type entry struct {
+	name   string
+	inode  uint64
+	mode   fs.FileMode
+	stat   *syscall.Stat_t
+	err    error
+	hidden bool
+	mount  bool
+	id     fileID
+	target *syscall.Stat_t
+	loop   bool
+	linkID fileID
+
+	deletionHint bool
+	frontier     bool
+	child        *dir
+}
+
+// dir is a directory that is to be, or has been, read during a walk.
+type dir struct {
+	path    string
+	depth   int
+	entries []entry
+	err     error
+	done    chan struct{}
+	output  uint64
+
+	claimed bool
+	counted bool
+}
+
+func newDir(path string, depth int) *dir {
+	return &dir{path: path, depth: depth, done: make(chan struct{})}
+}
+
+// dirQueue is a priority queue of directories waiting to be read, ordered by
+// path, so that the directories that will be output next are read first.
+//
+// It also limits the number of directories that have been popped, but not yet
+// released after being output, so that the readers can't get too far ahead of
+// the output.
+type dirQueue struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	dirs   dirHeap
+	limit  int
+	ahead  int
+	closed bool
+}
+
+func newDirQueue(limit int) *dirQueue {
+	q := &dirQueue{limit: limit}
+	q.cond.L = &q.mu
+
+	return q
+}
+
+func (q *dirQueue) push(d *dir) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	heap.Push(&q.dirs, d)
+	q.cond.Signal()
+}
+
+// pop returns the next unclaimed directory to read, blocking until one is
+// available and the limit of directories waiting to be output has not been
+// reached; returns nil once the queue is closed.
+func (q *dirQueue) pop() *dir {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed {
+		for q.ahead < q.limit && len(q.dirs) > 0 {
+			d := heap.Pop(&q.dirs).(*dir) //nolint:errcheck,forcetypeassert
+			if d.claimed {
+				continue
+			}
+
+			d.claimed = true
+			d.counted = true
+			q.ahead++
+
+			return d
+		}
+
+		q.cond.Wait()
+	}
+
+	return nil
+}
+
+// claim claims the given directory to be read by the caller, returning false
+// if it has already been claimed. Directories claimed this way do not count
+// towards the limit.
+func (q *dirQueue) claim(d *dir) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if d.claimed {
+		return false
+	}
+
+	d.claimed = true
+
+	return true
+}
+
+// release marks the given directory as output, allowing another directory to
+// be popped in its place.
+func (q *dirQueue) release(d *dir) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !d.counted {
+		return
+	}
+
+	d.counted = false
+	q.ahead--
+
+	q.cond.Signal()
+}
+
+func (q *dirQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+type dirHeap []*dir
+
+func (h dirHeap) Len() int           { return len(h) }
+func (h dirHeap) Less(i, j int) bool { return h[i].path < h[j].path }
+func (h dirHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *dirHeap) Push(x any)        { *h = append(*h, x.(*dir)) } //nolint:errcheck,forcetypeassert
+
+func (h *dirHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return d
+}
+
+// RootError is returned when a walk root can't be walked, because it doesn't
+// exist or is not a directory. It does not prevent other roots being walked.
+type RootError struct {
+	Root string
+	Err  error
+}
+
+func (e *RootError) Error() string {
+	return e.Root + ": " + e.Err.Error()
+}
+
+func (e *RootError) Unwrap() error {
+	return e.Err
+}
+
+type (
+	entryCallback func(path string, e *entry) error
+	errorCallback func(path string, err error)
+	dirCallback   func(path string, entries uint64) error
+
+	frontierCallback func(path string) error
+)
+
+// dirWalker reads directories concurrently, while outputting their entries in
+// the order set in the options.
+type dirWalker struct {
+	opts    *WalkOptions
+	filter  *filter
+	rootDev uint64
+	mounts  map[string]struct{}
+
+	visitedMu sync.Mutex
+	visited   map[fileID]struct{}
+
+	queue      *dirQueue
+	cb         entryCallback
+	errCB      errorCallback
+	doneCB     dirCallback
+	frontierCB frontierCallback
+
+	reading chan struct{}
+	buf     []byte
+}
+
+// walkDir walks the directory at the given path, passing every entry, including
+// the root, to the callback, in the order set in the options, which defaults
+// to lexical order, and passing any errors reading
+// directories to the error callback. Once a directory and all of its contents
+// have been output, its path and the number of its entries that were output
+// are passed to the done callback, which may be nil. Directories that are not
+// read, because they are at the maximum depth, are passed to the frontier
+// callback, which may also be nil, whether or not their entries are output.
+//
+// When resuming from a checkpoint, entries that were output before the
+// checkpoint are skipped, as are any directories that were completed.
+//
+// Returns a *RootError if the root is not a directory, or, with ETIMEDOUT, if
+// it can't be stat'd within the directory timeout, or an error if a callback
+// returns an error.
+func walkDir(root string, opts *WalkOptions, cb entryCallback, errCB errorCallback, doneCB dirCallback,
+	frontierCB frontierCallback) error {
+	if !strings.HasPrefix(root, "/") {
+		return &RootError{Root: root, Err: fs.ErrInvalid}
+	}
+
+	fi, err := withTimeout(opts.dirTimeout(), func() (fs.FileInfo, error) { return statRoot(root) })
+	if err != nil {
+		return &RootError{Root: root, Err: err}
+	} else if !fi.IsDir() {
+		return &RootError{Root: root, Err: fs.ErrInvalid}
+	}
+
+	f, err := newFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	stat := fi.Sys().(*syscall.Stat_t) //nolint:errcheck,forcetypeassert
+	w := &dirWalker{
+		opts:       opts,
+		filter:     f,
+		rootDev:    stat.Dev,
+		queue:      newDirQueue(opts.walkers() * aheadPerWalker),
+		cb:         cb,
+		errCB:      errCB,
+		doneCB:     doneCB,
+		frontierCB: frontierCB,
+		reading:    make(chan struct{}, opts.walkers()),
+		buf:        make([]byte, opts.readSize()),
+	}
+
+	if opts.oneFilesystem() {
+		if w.mounts, err = readMountSet(); err != nil {
+			return err
+		}
+	}
+
+	if opts.followSymlinks() {
+		w.visited = map[fileID]struct{}{{dev: stat.Dev, ino: stat.Ino}: {}}
+	}
+
+	defer w.queue.close()
+
+	for range opts.walkers() {
+		go w.reader()
+	}
+
+	rootPath := rootPath(root)
+	if resumeSkip(opts.resume(), rootPath) {
+		return nil
+	}
+
+	d := newDir(rootPath, 0)
+
+	w.queue.push(d)
+
+	rootEntry := &entry{inode: stat.Ino, mode: fs.ModeDir}
+
+	if opts.stat() {
+		rootEntry.stat = stat
+	}
+
+	if since := opts.since(); !since.IsZero() {
+		rootEntry.hidden, rootEntry.deletionHint = changedSince(false, stat, since)
+	}
+
+	if !rootEntry.hidden && !opts.skipRoots() && !resumeDone(opts.resume(), rootPath) {
+		if err := cb(rootPath, rootEntry); err != nil {
+			return err
+		}
+	}
+
+	return w.output(d)
+}
+
+// reader reads directories from the queue until it is closed.
+func (w *dirWalker) reader() {
+	buf := make([]byte, w.opts.readSize())
+
+	for d := w.queue.pop(); d != nil; d = w.queue.pop() {
+		w.read(d, buf)
+	}
+}
+
+// read reads the given directory using the given buffer, queuing any of its
+// subdirectories to be read. No more than the configured number of walkers
+// will read at once.
+func (w *dirWalker) read(d *dir, buf []byte) {
+	w.reading <- struct{}{}
+	defer func() { <-w.reading }()
+
+	if w.opts.dirTimeout() > 0 {
+		d.entries, d.err = w.scanWithTimeout(d.path)
+	} else {
+		d.entries, d.err = w.scan(d.path, buf)
+	}
+
+	w.queueChildren(d)
+
+	if w.opts.order() == OrderDirsFirst {
+		sortDirsFirst(d.entries)
+	}
+
+	close(d.done)
+}
+
+// scanWithTimeout scans the directory, giving up on it with ETIMEDOUT if the
+// read takes longer than the directory timeout. As the read may be abandoned,
+// it uses its own buffer, so that it can never touch the reader's buffer after
+// it has been given up on.
+func (w *dirWalker) scanWithTimeout(path string) ([]entry, error) {
+	buf := make([]byte, w.opts.readSize())
+
+	return withTimeout(w.opts.dirTimeout(), func() ([]entry, error) { return w.scan(path, buf) })
+}
+
+type timeoutResult[T any] struct {
+	v   T
+	err error
+}
+
+// withTimeout calls the given function in a separate goroutine, giving up on
+// it with ETIMEDOUT if it takes longer than the given timeout, or calls it
+// directly if the timeout is not positive. The goroutine is abandoned, so that
+// a hung syscall doesn't prevent the rest of the walk, and only returns its
+// results over its own channel, so that it can never touch shared state after
+// it has been given up on.
+func withTimeout[T any](timeout time.Duration, fn func() (T, error)) (T, error) {
+	if timeout <= 0 {
+		return fn()
+	}
+
+	ch := make(chan timeoutResult[T], 1)
+
+	go func() {
+		v, err := fn()
+
+		ch <- timeoutResult[T]{v, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-time.After(timeout):
+		var zero T
+
+		return zero, syscall.ETIMEDOUT
+	}
+}
+
+// scan reads the entries of the given directory, filters them, and stats them
+// if required.
+func (w *dirWalker) scan(path string, buf []byte) ([]entry, error) {
+	entries, err := scan(path, buf)
+
+	if w.opts.followSymlinks() {
+		resolveSymlinks(path, entries)
+	}
+
+	entries = w.filter.apply(path, entries)
+
+	if w.opts.stat() {
+		statEntries(path, entries)
+	}
+
+	if since := w.opts.since(); !since.IsZero() {
+		markUnchanged(entries, since)
+	}
+
+	if w.opts.oneFilesystem() {
+		w.markMounts(path, entries)
+	}
+
+	if w.opts.hardlinks() {
+		markHardlinks(path, entries)
+	}
+
+	return entries, err
+}
+
+// readMountSet returns the mount points of the current process, each with a
+// trailing slash to match directory paths in the walk.
+func readMountSet() (map[string]struct{}, error) {
+	mounts, err := ReadMounts(MountInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{}, len(mounts))
+
+	for _, mount := range mounts {
+		set[strings.TrimSuffix(mount, "/")+"/"] = struct{}{}
+	}
+
+	return set, nil
+}
+
+// markMounts marks the directories among the given entries that are on a
+// different device to the root of the walk, or that are listed as mount
+// points, so that they will not be walked.
+func (w *dirWalker) markMounts(path string, entries []entry) {
+	for n := range entries {
+		e := &entries[n]
+		if !e.mode.IsDir() {
+			continue
+		}
+
+		if _, ok := w.mounts[path+e.name]; ok {
+			e.mount = true
+
+			continue
+		}
+
+		stat := e.stat
+		if stat == nil {
+			stat = new(syscall.Stat_t)
+
+			if syscall.Lstat(path+e.name, stat) != nil {
+				continue
+			}
+		}
+
+		e.mount = stat.Dev != w.rootDev
+	}
+}
+
+// statEntries lstats each of the given entries of the directory at the given
+// path, recording any error against the entry.
+func statEntries(path string, entries []entry) {
+	for n := range entries {
+		e := &entries[n]
+		if e.hidden {
+			continue
+		}
+
+		e.stat = new(syscall.Stat_t)
+
+		if e.err = syscall.Lstat(path+e.name, e.stat); e.err != nil {
+			e.stat = nil
+		}
+	}
+}
+
+// queueChildren adds all of the subdirectories of the given directory, and any
+// symlinks to directories that may be followed, to the read queue, unless they
+// would be beyond the maximum depth, in which case they are marked as the
+// frontier.
+//
+// Whether a directory reached by more than one path is walked is only decided
+// once its parent is output, so directories that have not yet been seen are
+// read ahead and later discarded if need be.
+func (w *dirWalker) queueChildren(d *dir) {
+	if w.atMaxDepth(d) {
+		markFrontier(d.entries)
+
+		return
+	}
+
+	for n := range d.entries {
+		e := &d.entries[n]
+
+		path, ok := w.childPath(d, e)
+		if !ok || resumeSkip(w.opts.resume(), path) {
+			continue
+		}
+
+		e.child = newDir(path, d.depth+1)
+
+		w.queue.push(e.child)
+	}
+}
+
+// atMaxDepth returns true if the subdirectories of the given directory would be
+// beyond the maximum depth.
+func (w *dirWalker) atMaxDepth(d *dir) bool {
+	maxDepth := w.opts.maxDepth()
+
+	return maxDepth > 0 && d.depth+1 >= maxDepth
+}
+
+// childPath returns the path of the directory that will be walked for the given
+// entry of the given directory, if any.
+func (w *dirWalker) childPath(d *dir, e *entry) (string, bool) {
+	switch {
+	case e.err != nil || e.mount:
+		return "", false
+	case e.mode.IsDir():
+		return d.path + e.name, !w.opts.followSymlinks() || !w.seen(e.id)
+	case e.target != nil:
+		return d.path + e.name + "/", !w.targetMount(e) && !w.seen(fileID{dev: e.target.Dev, ino: e.target.Ino})
+	default:
+		return "", false
+	}
+}
+
+// markFrontier marks the directories among the given entries that will not be
+// read because they are at the maximum depth.
+func markFrontier(entries []entry) {
+	for n := range entries {
+		if e := &entries[n]; e.mode.IsDir() && !e.mount && e.err == nil {
+			e.frontier = true
+		}
+	}
+}
+
+// output outputs the given directory, recursing into subdirectories, or, for a
+// breadth-first walk, queuing them to be output after the rest of the current
+// depth.
+func (w *dirWalker) output(d *dir) error {
+	if w.opts.order() != OrderBreadthFirst {
+		return w.outputDir(d, w.output)
+	}
+
+	queue := []*dir{d}
+	next := func(child *dir) error {
+		queue = append(queue, child)
+
+		return nil
+	}
+
+	for ; len(queue) > 0; queue = queue[1:] {
+		head := queue[0]
+		queue[0] = nil
+
+		if err := w.outputDir(head, next); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// outputDir waits for the given directory to be read, reading it itself if no
+// reader has started on it, and then outputs its entries, passing each
+// subdirectory being walked to the given function after its entry. Once output,
+// the entries of the directory are released.
+func (w *dirWalker) outputDir(d *dir, child func(*dir) error) error {
+	if w.queue.claim(d) {
+		w.read(d, w.buf)
+	}
+
+	<-d.done
+
+	if d.err != nil {
+		w.errCB(d.path, d.err)
+	}
+
+	if w.opts.followSymlinks() && !w.atMaxDepth(d) {
+		w.followSymlinks(d)
+	}
+
+	for n := range d.entries {
+		e := &d.entries[n]
+
+		if err := w.outputEntry(d, e); err != nil {
+			return err
+		}
+
+		if e.frontier && w.frontierCB != nil {
+			if err := w.frontierCB(d.path + e.name); err != nil {
+				return err
+			}
+		}
+
+		if e.child == nil || e.err != nil {
+			continue
+		}
+
+		if err := child(e.child); err != nil {
+			return err
+		}
+
+		e.child = nil
+	}
+
+	d.entries = nil
+
+	w.queue.release(d)
+
+	if w.doneCB == nil {
+		return nil
+	}
+
+	return w.doneCB(d.path, d.output)
+}
+
+// outputEntry outputs the given entry of the given directory, unless it was
+// output before the checkpoint being resumed from.
+func (w *dirWalker) outputEntry(d *dir, e *entry) error {
+	path := d.path + e.name
+
+	if !resumeDone(w.opts.resume(), path) {
+		if e.err != nil {
+			w.errCB(path, e.err)
+
+			return nil
+		}
+
+		if !e.hidden {
+			if err := w.cb(path, e); err != nil {
+				return err
+			}
+
+			d.output++
+		}
+
+		if e.loop {
+			w.errCB(path, syscall.ELOOP)
+		}
+	}
+
+	return nil
+}
+
+// scanDir reads the entries of the directory at the given path, which must end
+// with a slash, returning them sorted by name.
+func scanDir(path string, buf []byte) ([]entry, error) {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defer syscall.Close(fd)
+
+	var entries []entry
+
+	for {
+		n, err := syscall.ReadDirent(fd, buf)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		} else if err != nil || n <= 0 {
+			sortEntries(entries)
+
+			return entries, err
+		}
+
+		entries = parseDirents(path, buf[:n], entries)
+	}
+}
+
+// parseDirents parses the linux_dirent64 structs in the given buffer, appending
+// them to the given entries.
+func parseDirents(path string, buf []byte, entries []entry) []entry {
+	for len(buf) > direntNameStart {
+		reclen := binary.NativeEndian.Uint16(buf[direntReclenStart:direntTypeStart])
+		inode := binary.NativeEndian.Uint64(buf[direntInodeStart:])
+		typ := buf[direntTypeStart]
+		name := buf[direntNameStart:reclen]
+		buf = buf[reclen:]
+
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+
+		if inode == 0 || string(name) == "." || string(name) == ".." {
+			continue
+		}
+
+		e := entry{name: string(name), inode: inode, mode: direntType(path, name, typ)}
+
+		if e.mode.IsDir() {
+			e.name += "/"
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// direntType converts the dirent type to the type bits of a fs.FileMode,
+// falling back to an lstat for filesystems that do not provide the type.
+func direntType(path string, name []byte, typ uint8) fs.FileMode {
+	if typ == syscall.DT_UNKNOWN {
+		var stat syscall.Stat_t
+
+		if err := syscall.Lstat(path+string(name), &stat); err != nil {
+			return 0
+		}
+
+		return modeType(stat.Mode)
+	}
+
+	return modeType(uint32(typ) << 12) //nolint:mnd
+}
+
+// modeType converts the type bits of a stat mode to those of a fs.FileMode.
+func modeType(mode uint32) fs.FileMode {
+	return (&fileInfo{data: syscall.Stat_t{Mode: mode & syscall.S_IFMT}}).Mode().Type()
+}
+
+func sortEntries(entries []entry) {
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.name, b.name) })
+}
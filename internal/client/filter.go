@@ -0,0 +1,218 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const regexPrefix = "re:"
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// Patterns is a flag.Value that collects name patterns. Each pattern is either
+// a glob, as understood by path.Match, or, when prefixed with "re:", a regular
+// expression. Patterns are matched against the name of an entry, without any
+// trailing slash.
+type Patterns []string
+
+func (p *Patterns) String() string {
+	if p == nil {
+		return ""
+	}
+
+	return strings.Join(*p, ",")
+}
+
+// Set checks that the given pattern is valid and adds it to the list.
+func (p *Patterns) Set(v string) error {
+	if _, err := compilePattern(v); err != nil {
+		return err
+	}
+
+	*p = append(*p, v)
+
+	return nil
+}
+
+// Paths is a flag.Value that collects paths.
+type Paths []string
+
+func (p *Paths) String() string {
+	if p == nil {
+		return ""
+	}
+
+	return strings.Join(*p, ",")
+}
+
+// Set adds the given path to the list.
+func (p *Paths) Set(v string) error {
+	*p = append(*p, v)
+
+	return nil
+}
+
+// matcher is a compiled set of Patterns.
+type matcher []func(string) bool
+
+func compilePattern(pattern string) (func(string) bool, error) {
+	if re, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+		r, err := regexp.Compile(re)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidPattern, err)
+		}
+
+		return r.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.Join(ErrInvalidPattern, err)
+	}
+
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name) //nolint:errcheck
+
+		return ok
+	}, nil
+}
+
+func (p Patterns) compile() (matcher, error) {
+	m := make(matcher, 0, len(p))
+
+	for _, pattern := range p {
+		fn, err := compilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		m = append(m, fn)
+	}
+
+	return m, nil
+}
+
+// match returns true if the given name, ignoring any trailing slash, matches
+// any of the patterns.
+func (m matcher) match(name string) bool {
+	name = strings.TrimSuffix(name, "/")
+
+	for _, fn := range m {
+		if fn(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filter is the compiled form of the filtering walk options.
+type filter struct {
+	include, exclude, prune matcher
+	skip                    map[string]struct{}
+}
+
+func newFilter(opts *WalkOptions) (*filter, error) {
+	if opts == nil {
+		return new(filter), nil
+	}
+
+	var (
+		f   filter
+		err error
+	)
+
+	if f.include, err = opts.Include.compile(); err != nil {
+		return nil, err
+	}
+
+	if f.exclude, err = opts.Exclude.compile(); err != nil {
+		return nil, err
+	}
+
+	if f.prune, err = opts.Prune.compile(); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Skip) > 0 {
+		f.skip = make(map[string]struct{}, len(opts.Skip))
+
+		for _, path := range opts.Skip {
+			f.skip[rootPath(path)] = struct{}{}
+		}
+	}
+
+	return &f, nil
+}
+
+// apply removes pruned and skipped directories, and entries that are excluded
+// or not included, from the given entries of the directory at the given path.
+// Directories that are excluded or not included are kept, so that they can
+// still be walked, but are marked as hidden.
+func (f *filter) apply(path string, entries []entry) []entry {
+	if len(f.include) == 0 && len(f.exclude) == 0 && len(f.prune) == 0 && len(f.skip) == 0 {
+		return entries
+	}
+
+	return deleteEntries(entries, func(e *entry) bool {
+		isDir := e.mode.IsDir() || e.target != nil
+
+		if isDir && (f.prune.match(e.name) || f.skipped(path+e.name)) {
+			return true
+		}
+
+		e.hidden = f.exclude.match(e.name) || len(f.include) > 0 && !f.include.match(e.name)
+
+		return e.hidden && !isDir
+	})
+}
+
+// skipped returns true if the directory at the given path, which may not have
+// a trailing slash, is to be skipped.
+func (f *filter) skipped(path string) bool {
+	_, ok := f.skip[strings.TrimSuffix(path, "/")+"/"]
+
+	return ok
+}
+
+// deleteEntries is like slices.DeleteFunc, but passes a pointer to each
+// element so that it can be modified.
+func deleteEntries(entries []entry, del func(*entry) bool) []entry {
+	kept := entries[:0]
+
+	for n := range entries {
+		if !del(&entries[n]) {
+			kept = append(kept, entries[n])
+		}
+	}
+
+	clear(entries[len(kept):])
+
+	return kept
+}
@@ -0,0 +1,180 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID uniquely identifies a directory, so that it is only walked once when
+// following symlinks.
+type fileID struct {
+	dev, ino uint64
+}
+
+// resolveSymlinks records the targets of any symlinks to directories among the
+// given entries, and the IDs of any directories, so that loops and duplicates
+// can be detected.
+func resolveSymlinks(path string, entries []entry) {
+	for n := range entries {
+		e := &entries[n]
+
+		switch {
+		case e.mode&fs.ModeSymlink != 0:
+			var stat syscall.Stat_t
+
+			if syscall.Stat(path+e.name, &stat) == nil && stat.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+				e.target = &stat
+			}
+		case e.mode.IsDir():
+			var stat syscall.Stat_t
+
+			if syscall.Lstat(path+e.name, &stat) == nil {
+				e.id = fileID{dev: stat.Dev, ino: stat.Ino}
+			}
+		}
+	}
+}
+
+// visit records the given directory as being walked, returning false if it
+// has already been seen.
+func (w *dirWalker) visit(id fileID) bool {
+	w.visitedMu.Lock()
+	defer w.visitedMu.Unlock()
+
+	if _, ok := w.visited[id]; ok {
+		return false
+	}
+
+	w.visited[id] = struct{}{}
+
+	return true
+}
+
+// seen returns true if the given directory has already been recorded as being
+// walked, in which case any other path to it will not be walked.
+func (w *dirWalker) seen(id fileID) bool {
+	w.visitedMu.Lock()
+	defer w.visitedMu.Unlock()
+
+	_, ok := w.visited[id]
+
+	return ok
+}
+
+// followSymlinks is called as the given directory is output, and records its
+// subdirectories as seen, marking any that have already been seen as loops,
+// and then follows any symlinks to directories that haven't already been seen,
+// resorting the entries to account for the renamed symlinks.
+//
+// As directories are output in a fixed order, this means that which path to a
+// directory gets walked does not depend on the order the readers read them.
+func (w *dirWalker) followSymlinks(d *dir) {
+	for n := range d.entries {
+		if e := &d.entries[n]; e.id != (fileID{}) && !w.visit(e.id) {
+			e.loop = true
+
+			w.discardChild(e)
+		}
+	}
+
+	followed := false
+
+	for n := range d.entries {
+		if e := &d.entries[n]; e.target != nil {
+			followed = w.follow(e) || followed
+		}
+	}
+
+	if !followed {
+		return
+	}
+
+	sortEntries(d.entries)
+
+	if w.opts.order() == OrderDirsFirst {
+		sortDirsFirst(d.entries)
+	}
+}
+
+// follow turns the given symlink entry into an entry for the directory it
+// points to, returning false, and marking the entry as a loop, if that
+// directory has already been seen.
+func (w *dirWalker) follow(e *entry) bool {
+	if !w.visit(fileID{dev: e.target.Dev, ino: e.target.Ino}) {
+		e.loop = true
+
+		w.discardChild(e)
+
+		return false
+	}
+
+	e.name += "/"
+	e.mode = fs.ModeDir
+	e.inode = e.target.Ino
+	e.mount = w.targetMount(e)
+
+	if e.stat != nil {
+		e.stat = e.target
+	}
+
+	return true
+}
+
+// targetMount returns true if the directory the given symlink entry points to
+// is on a different filesystem, and so will not be walked.
+func (w *dirWalker) targetMount(e *entry) bool {
+	return w.opts.oneFilesystem() && e.target.Dev != w.rootDev
+}
+
+// discardChild abandons the directory queued to be read for the given entry, if
+// any.
+func (w *dirWalker) discardChild(e *entry) {
+	if e.child != nil {
+		w.discard(e.child)
+
+		e.child = nil
+	}
+}
+
+// discard abandons the given directory, which was queued to be read ahead of
+// the output but will not be output, along with any of its subdirectories.
+func (w *dirWalker) discard(d *dir) {
+	if w.queue.claim(d) {
+		return
+	}
+
+	<-d.done
+
+	for n := range d.entries {
+		w.discardChild(&d.entries[n])
+	}
+
+	d.entries = nil
+
+	w.queue.release(d)
+}
@@ -0,0 +1,75 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import "syscall"
+
+// markHardlinks records the IDs of any regular files, among the given entries
+// of the directory at the given path, that have more than one link, so that
+// the paths sharing an inode can be identified as they are output.
+func markHardlinks(path string, entries []entry) {
+	for n := range entries {
+		e := &entries[n]
+		if e.hidden || e.err != nil || !e.mode.IsRegular() {
+			continue
+		}
+
+		stat := e.stat
+		if stat == nil {
+			stat = new(syscall.Stat_t)
+
+			if syscall.Lstat(path+e.name, stat) != nil {
+				continue
+			}
+		}
+
+		if stat.Nlink > 1 {
+			e.linkID = fileID{dev: stat.Dev, ino: stat.Ino}
+		}
+	}
+}
+
+// hardlinkOf returns the path of the first entry output with the same inode as
+// the given entry, recording the given path as the first if there isn't one.
+//
+// Must be called with the walkWriter lock held.
+func (w *walkWriter) hardlinkOf(path string, e *entry) string {
+	if e.linkID == (fileID{}) {
+		return ""
+	}
+
+	if first, ok := w.links[e.linkID]; ok {
+		return first
+	}
+
+	if w.links == nil {
+		w.links = make(map[fileID]string)
+	}
+
+	w.links[e.linkID] = path
+
+	return ""
+}
@@ -0,0 +1,94 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"bufio"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is the path to the mountinfo file of the current process.
+const MountInfo = "/proc/self/mountinfo"
+
+// ReadMounts returns the mount points listed in the given mountinfo file,
+// sorted longest first.
+func ReadMounts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	mounts := []string{"/"}
+	s := bufio.NewScanner(f)
+
+	for s.Scan() {
+		const mountPointField = 4
+
+		fields := strings.Fields(s.Text())
+		if len(fields) > mountPointField {
+			mounts = append(mounts, unescapeMount(fields[mountPointField]))
+		}
+	}
+
+	slices.SortFunc(mounts, func(a, b string) int { return len(b) - len(a) })
+
+	return slices.Compact(mounts), s.Err()
+}
+
+// unescapeMount replaces the octal escapes used in mountinfo with the
+// characters they represent.
+func unescapeMount(mount string) string {
+	var sb strings.Builder
+
+	for {
+		pos := strings.IndexByte(mount, '\\')
+		if pos == -1 || len(mount) < pos+4 {
+			break
+		}
+
+		c, err := strconv.ParseUint(mount[pos+1:pos+4], 8, 8)
+		if err != nil {
+			sb.WriteString(mount[:pos+1])
+			mount = mount[pos+1:]
+
+			continue
+		}
+
+		sb.WriteString(mount[:pos])
+		sb.WriteByte(byte(c))
+
+		mount = mount[pos+4:]
+	}
+
+	sb.WriteString(mount)
+
+	return sb.String()
+}
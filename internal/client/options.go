@@ -0,0 +1,196 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrInvalidRlimit = errors.New("invalid rlimit")
+
+// Options contains the settings used to spawn a statter process.
+type Options struct {
+	// Timeout is the stat timeout passed to the statter. A zero value will use
+	// the statter default.
+	Timeout time.Duration
+
+	// Args are extra arguments passed to the statter, after the flags that set
+	// its mode and walk options, but before any walk path.
+	Args []string
+
+	// Env is the environment of the statter. A nil value will inherit the
+	// environment of the current process.
+	Env []string
+
+	// Dir is the working directory of the statter. An empty value will use the
+	// working directory of the current process.
+	Dir string
+
+	// Stderr will receive the stderr output of the statter.
+	Stderr io.Writer
+
+	// Rlimits are resource limits, keyed by syscall.RLIMIT_* resource, that the
+	// statter will apply to itself on startup.
+	Rlimits Rlimits
+
+	// Walk contains the settings that only apply to walks.
+	Walk WalkOptions
+
+	// Handlers contains the callbacks for the control records of a walk.
+	Handlers WalkHandlers
+}
+
+// command creates the exec.Cmd for the given statter executable, with the given
+// mode flags placed before the caller supplied arguments, so that those can't
+// change how the mode flags are parsed, and the given operands after them.
+func (o *Options) command(exe string, flags []string, operands ...string) *exec.Cmd {
+	args := slices.Concat(flags, o.args(), operands)
+
+	if o == nil {
+		return exec.Command(exe, args...) //nolint:noctx
+	}
+
+	cmd := exec.Command(exe, args...) //nolint:noctx
+	cmd.Env = o.Env
+	cmd.Dir = o.Dir
+	cmd.Stderr = o.Stderr
+
+	return cmd
+}
+
+// walkArgs returns the statter arguments needed to set the walk options.
+func (o *Options) walkArgs() []string {
+	if o == nil {
+		return nil
+	}
+
+	return o.Walk.args()
+}
+
+func (o *Options) args() []string {
+	if o == nil {
+		return nil
+	}
+
+	args := make([]string, 0, len(o.Args)+len(o.Rlimits)+1)
+
+	if o.Timeout > 0 {
+		args = append(args, "-timeout="+o.Timeout.String())
+	}
+
+	for _, resource := range slices.Sorted(maps.Keys(o.Rlimits)) {
+		l := o.Rlimits[resource]
+
+		args = append(args, fmt.Sprintf("-rlimit=%d=%d:%d", resource, l.Cur, l.Max))
+	}
+
+	return append(args, o.Args...)
+}
+
+var rlimitNames = map[string]int{ //nolint:gochecknoglobals
+	"as":     syscall.RLIMIT_AS,
+	"core":   syscall.RLIMIT_CORE,
+	"cpu":    syscall.RLIMIT_CPU,
+	"data":   syscall.RLIMIT_DATA,
+	"fsize":  syscall.RLIMIT_FSIZE,
+	"nofile": syscall.RLIMIT_NOFILE,
+	"stack":  syscall.RLIMIT_STACK,
+}
+
+// Rlimits is a flag.Value that collects resource limits in the form
+// resource=soft[:hard], where resource is either a number or one of as, core,
+// cpu, data, fsize, nofile, or stack.
+type Rlimits map[int]syscall.Rlimit
+
+func (r Rlimits) String() string {
+	limits := make([]string, 0, len(r))
+
+	for _, resource := range slices.Sorted(maps.Keys(r)) {
+		limits = append(limits, fmt.Sprintf("%d=%d:%d", resource, r[resource].Cur, r[resource].Max))
+	}
+
+	return strings.Join(limits, ",")
+}
+
+// Set parses a single resource limit and adds it to the map.
+func (r Rlimits) Set(v string) error {
+	name, limits, ok := strings.Cut(v, "=")
+	if !ok {
+		return ErrInvalidRlimit
+	}
+
+	resource, ok := rlimitNames[name]
+	if !ok {
+		n, err := strconv.ParseUint(name, 10, 8)
+		if err != nil {
+			return ErrInvalidRlimit
+		}
+
+		resource = int(n)
+	}
+
+	soft, hard, ok := strings.Cut(limits, ":")
+	if !ok {
+		hard = soft
+	}
+
+	var (
+		l    syscall.Rlimit
+		err1 error
+		err2 error
+	)
+
+	l.Cur, err1 = strconv.ParseUint(soft, 10, 64)
+	l.Max, err2 = strconv.ParseUint(hard, 10, 64)
+
+	if err1 != nil || err2 != nil {
+		return ErrInvalidRlimit
+	}
+
+	r[resource] = l
+
+	return nil
+}
+
+// Apply sets the resource limits for the current process.
+func (r Rlimits) Apply() error {
+	for resource, l := range r {
+		if err := syscall.Setrlimit(resource, &l); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", resource, err)
+		}
+	}
+
+	return nil
+}
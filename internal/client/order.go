@@ -0,0 +1,94 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrInvalidOrder is returned when setting an unknown walk order, or when
+// resuming a walk that isn't in lexical order.
+var ErrInvalidOrder = errors.New("invalid walk order")
+
+// WalkOrder is a flag.Value that determines the order in which the entries of
+// a walk are output.
+type WalkOrder uint8
+
+const (
+	// OrderLexical outputs entries in the lexical order of their full paths,
+	// with directories having a trailing slash.
+	OrderLexical WalkOrder = iota
+
+	// OrderBreadthFirst outputs all of the entries at one depth, in lexical
+	// order, before any entries at the next depth.
+	OrderBreadthFirst
+
+	// OrderDirsFirst outputs, for each directory, its subdirectories, each
+	// followed by its contents, before its other entries.
+	OrderDirsFirst
+)
+
+var orderNames = [...]string{ //nolint:gochecknoglobals
+	OrderLexical:      "lexical",
+	OrderBreadthFirst: "breadth",
+	OrderDirsFirst:    "dirsfirst",
+}
+
+func (o *WalkOrder) String() string {
+	if o == nil || int(*o) >= len(orderNames) {
+		return orderNames[OrderLexical]
+	}
+
+	return orderNames[*o]
+}
+
+// Set sets the order from one of lexical, breadth, or dirsfirst.
+func (o *WalkOrder) Set(v string) error {
+	n := slices.Index(orderNames[:], v)
+	if n < 0 {
+		return ErrInvalidOrder
+	}
+
+	*o = WalkOrder(n) //nolint:gosec
+
+	return nil
+}
+
+// sortDirsFirst moves the directories among the given entries before the
+// other entries, keeping each group in lexical order.
+func sortDirsFirst(entries []entry) {
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch {
+		case a.mode.IsDir() == b.mode.IsDir():
+			return 0
+		case a.mode.IsDir():
+			return -1
+		default:
+			return 1
+		}
+	})
+}
@@ -0,0 +1,98 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+const (
+	pong = 1
+
+	// PingFD is the file descriptor on which a statter started with the -ping
+	// flag will answer pings, independently of its request loop.
+	PingFD = 3
+)
+
+// Ping takes the net.Conn from CreateStatter and sends a no-op request that
+// the statter will answer without touching the filesystem.
+func Ping(c io.ReadWriter) error {
+	if err := writePath(c, "", modePing); err != nil {
+		return err
+	}
+
+	var buf [1]byte
+
+	if err := readBuf(c, buf[:]); err != nil {
+		return err
+	}
+
+	if buf[0] != pong {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
+
+func writePong() error {
+	_, err := conn.Write([]byte{pong})
+
+	return err
+}
+
+// ServePings answers pings read from the given connection until it is closed.
+// It is run alongside the request loop so that a statter can show that it is
+// responsive even while a request is stuck.
+func ServePings(c io.ReadWriter) error {
+	var buf [modeLengthSize]byte
+
+	for {
+		if err := readBuf(c, buf[:]); err != nil {
+			return err
+		}
+
+		if mode(buf[0]) != modePing {
+			return ErrInvalidMode
+		}
+
+		if _, err := c.Write([]byte{pong}); err != nil {
+			return err
+		}
+	}
+}
+
+// pingPair returns a connected pair of sockets, the first to be kept by the
+// client and the second to be passed to the statter as PingFD.
+func pingPair() (*os.File, *os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return os.NewFile(uintptr(fds[0]), "ping"), os.NewFile(uintptr(fds[1]), "ping"), nil
+}
@@ -0,0 +1,341 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+	"time"
+)
+
+const (
+	defaultPlanDepth = 3
+
+	shardPathsStart = 8
+	pathCountSize   = 4
+	pathLenSize     = 2
+)
+
+// Shard is a part of the planned trees that can be walked separately, by
+// walking its Roots with WalkOptions.Skip set to its Skip paths.
+type Shard struct {
+	Roots []string
+	Skip  []string
+
+	// Estimate is the number of entries found in the shard while planning.
+	Estimate uint64
+}
+
+func (s *Shard) append(buf []byte) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, s.Estimate)
+	buf = appendPaths(buf, s.Roots)
+
+	return appendPaths(buf, s.Skip)
+}
+
+func appendPaths(buf []byte, paths []string) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(paths))) //nolint:gosec
+
+	for _, path := range paths {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(path))) //nolint:gosec
+		buf = append(buf, path...)
+	}
+
+	return buf
+}
+
+func readShard(buf []byte) (*Shard, error) {
+	if len(buf) < shardPathsStart {
+		return nil, ErrInvalidResponse
+	}
+
+	s := &Shard{Estimate: binary.LittleEndian.Uint64(buf)}
+
+	var ok bool
+
+	if s.Roots, buf, ok = readPaths(buf[shardPathsStart:]); !ok {
+		return nil, ErrInvalidResponse
+	}
+
+	if s.Skip, buf, ok = readPaths(buf); !ok || len(buf) != 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	return s, nil
+}
+
+// readPaths reads a count prefixed list of length prefixed paths from the
+// given buffer, returning the paths and the rest of the buffer.
+func readPaths(buf []byte) ([]string, []byte, bool) {
+	if len(buf) < pathCountSize {
+		return nil, nil, false
+	}
+
+	count := binary.LittleEndian.Uint32(buf)
+	buf = buf[pathCountSize:]
+
+	var paths []string
+
+	for range count {
+		if len(buf) < pathLenSize {
+			return nil, nil, false
+		}
+
+		end := pathLenSize + int(binary.LittleEndian.Uint16(buf))
+		if len(buf) < end {
+			return nil, nil, false
+		}
+
+		paths = append(paths, string(buf[pathLenSize:end]))
+		buf = buf[end:]
+	}
+
+	return paths, buf, true
+}
+
+// planNode is a directory found while planning, with the number of its
+// entries that aren't planned directories, and the total number of entries
+// within it, including itself.
+type planNode struct {
+	path     string
+	depth    int
+	files    uint64
+	total    uint64
+	children []*planNode
+}
+
+// planner builds a tree of the directories found while planning.
+type planner struct {
+	w     *walkWriter
+	depth int
+	root  string
+	dirs  map[string]*planNode
+	roots []*planNode
+}
+
+// Plan walks the given roots, to a limited depth, according to the given
+// options, and then writes a shard record for each of up to opts.Shards
+// shards, followed by a summary record.
+//
+// Directories at the plan depth are not split, and are read, but not walked,
+// so that their sizes can be estimated from their direct entries.
+//
+// Shards are made by splitting the largest subtrees into their subdirectories
+// and the remaining entries of the directory, until no subtree that can be
+// split is larger than an even share of the total, and then giving the
+// resulting parts, largest first, to the smallest shard.
+func Plan(roots []string, opts *WalkOptions) {
+	w := &walkWriter{counters: counters{start: time.Now()}}
+	planOpts := opts.planOptions()
+	p := &planner{w: w, depth: planOpts.MaxDepth}
+
+	planOpts.MaxDepth++
+
+	for _, root := range dedupRoots(roots, opts.skip()) {
+		p.root = rootPath(root)
+		p.dirs = make(map[string]*planNode)
+
+		if err := walkDir(root, planOpts, p.entry, w.ErrCallback, p.done, nil); err != nil && !w.rootError(err) {
+			w.WriteError(err)
+
+			return
+		}
+	}
+
+	for _, shard := range p.shards(opts.shards()) {
+		if err := w.writeControl(controlShard, string(shard.append(nil))); err != nil {
+			return
+		}
+	}
+
+	w.writeControl(controlSummary, string(w.counters.summary().append(nil))) //nolint:errcheck
+}
+
+// entry adds the given entry to the tree, as a planned directory if it is a
+// directory that can be walked as a root.
+func (p *planner) entry(path string, e *entry) error {
+	p.w.mu.Lock()
+	p.w.counters.entry(e, false)
+	p.w.mu.Unlock()
+
+	node := &planNode{path: path, total: 1}
+
+	if path == p.root {
+		p.roots = append(p.roots, node)
+		p.dirs[path] = node
+
+		return nil
+	}
+
+	parent, ok := p.dirs[parentDir(path)]
+	if !ok {
+		return nil
+	}
+
+	if !e.mode.IsDir() || e.mount || parent.depth >= p.depth {
+		parent.files++
+
+		return nil
+	}
+
+	node.depth = parent.depth + 1
+	parent.children = append(parent.children, node)
+	p.dirs[path] = node
+
+	return nil
+}
+
+// done totals the entries within the directory at the given path, once it has
+// been completely walked.
+func (p *planner) done(path string, _ uint64) error {
+	p.w.mu.Lock()
+	p.w.counters.dirs++
+	p.w.mu.Unlock()
+
+	node, ok := p.dirs[path]
+	if !ok {
+		return nil
+	}
+
+	node.total = 1 + node.files
+
+	for _, child := range node.children {
+		node.total += child.total
+	}
+
+	delete(p.dirs, path)
+
+	return nil
+}
+
+// planUnit is a part of a tree that will be walked as a single root, skipping
+// the subdirectories of its directory if it has been split.
+type planUnit struct {
+	node   *planNode
+	weight uint64
+	split  bool
+}
+
+// shards splits the planned trees into up to the given number of shards.
+func (p *planner) shards(n int) []*Shard {
+	units := p.units(n)
+
+	slices.SortStableFunc(units, func(a, b *planUnit) int {
+		return cmp.Or(cmp.Compare(b.weight, a.weight), cmp.Compare(a.node.path, b.node.path))
+	})
+
+	shards := make([]*Shard, min(n, len(units)))
+
+	for i := range shards {
+		shards[i] = new(Shard)
+	}
+
+	for _, u := range units {
+		s := slices.MinFunc(shards, func(a, b *Shard) int {
+			return cmp.Compare(a.Estimate, b.Estimate)
+		})
+
+		s.Estimate += u.weight
+		s.Roots = append(s.Roots, u.node.path)
+
+		if u.split {
+			for _, child := range u.node.children {
+				s.Skip = append(s.Skip, child.path)
+			}
+		}
+	}
+
+	for _, s := range shards {
+		s.merge()
+	}
+
+	return shards
+}
+
+// units splits the largest planned subtrees until there are none larger than
+// an even share, for the given number of shards, that can be split.
+func (p *planner) units(n int) []*planUnit {
+	var total uint64
+
+	units := make([]*planUnit, 0, len(p.roots))
+
+	for _, root := range p.roots {
+		total += root.total
+		units = append(units, &planUnit{node: root, weight: root.total})
+	}
+
+	target := total / uint64(max(n, 1)) //nolint:gosec
+
+	for {
+		var largest *planUnit
+
+		for _, u := range units {
+			if !u.split && len(u.node.children) > 0 && u.weight > target && (largest == nil || u.weight > largest.weight) {
+				largest = u
+			}
+		}
+
+		if largest == nil {
+			return units
+		}
+
+		largest.split = true
+		largest.weight = 1 + largest.node.files
+
+		for _, child := range largest.node.children {
+			units = append(units, &planUnit{node: child, weight: child.total})
+		}
+	}
+}
+
+// merge removes any roots of the shard that would be skipped by a split
+// directory in the same shard, along with the skip, so that they are walked as
+// part of that directory, and then sorts the roots and skips.
+func (s *Shard) merge() {
+	skip := make(map[string]bool, len(s.Skip))
+
+	for _, path := range s.Skip {
+		skip[path] = true
+	}
+
+	s.Roots = slices.DeleteFunc(s.Roots, func(root string) bool {
+		if !skip[root] {
+			return false
+		}
+
+		skip[root] = false
+
+		return true
+	})
+
+	s.Skip = slices.DeleteFunc(s.Skip, func(path string) bool {
+		return !skip[path]
+	})
+
+	slices.Sort(s.Roots)
+	slices.Sort(s.Skip)
+}
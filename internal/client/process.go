@@ -0,0 +1,254 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	stderrTailSize = 8192
+
+	// exitWait is how long to wait for a statter to exit once its pipe has
+	// died before killing it, and again before giving up on it.
+	exitWait = time.Second
+)
+
+// Exit codes used by the statter to show why it exited. Go uses 2 for an
+// unrecovered panic, and the flag package uses it for invalid flags.
+const (
+	ExitTimeout     = 3
+	ExitInvalidMode = 4
+)
+
+var errNotExited = errors.New("statter did not exit")
+
+// ExitCode returns the exit code the statter should use when exiting due to the
+// given error.
+func ExitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return ExitTimeout
+	case errors.Is(err, ErrInvalidMode):
+		return ExitInvalidMode
+	default:
+		return 1
+	}
+}
+
+// StatterExitedError is returned when the pipe to a statter dies, and contains
+// the exit status of the statter and the tail of its stderr output.
+//
+// It will match io.EOF with errors.Is, along with ErrTimeout or ErrInvalidMode
+// when the exit code shows that was the reason the statter exited.
+type StatterExitedError struct {
+	// ExitCode is the exit code of the statter, or -1 if it was killed by a
+	// signal.
+	ExitCode int
+
+	// Signal is the signal that killed the statter, if any. A SIGKILL will
+	// often indicate that it was killed by the OOM killer.
+	Signal syscall.Signal
+
+	// Stderr contains the last few KiB of the stderr output of the statter.
+	Stderr string
+
+	// Err is the error returned from waiting for the statter process.
+	Err error
+}
+
+func (e *StatterExitedError) Error() string {
+	msg := "statter exited: " + e.Err.Error()
+
+	if line := e.lastLine(); line != "" {
+		msg += ": " + line
+	}
+
+	return msg
+}
+
+func (e *StatterExitedError) lastLine() string {
+	stderr := strings.TrimRight(e.Stderr, "\n")
+
+	return stderr[strings.LastIndexByte(stderr, '\n')+1:]
+}
+
+// Panicked returns true if the stderr output of the statter shows that it
+// panicked.
+func (e *StatterExitedError) Panicked() bool {
+	return strings.HasPrefix(e.Stderr, "panic: ") || strings.Contains(e.Stderr, "\npanic: ")
+}
+
+func (e *StatterExitedError) Unwrap() []error {
+	errs := []error{io.EOF, e.Err}
+
+	switch e.ExitCode {
+	case ExitTimeout:
+		errs = append(errs, ErrTimeout)
+	case ExitInvalidMode:
+		errs = append(errs, ErrInvalidMode)
+	}
+
+	return errs
+}
+
+// tail is an io.Writer that keeps the last stderrTailSize bytes written to it.
+type tail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+
+	if len(t.buf) > stderrTailSize {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-stderrTailSize:]...)
+	}
+
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return string(t.buf)
+}
+
+// process is a running statter, along with the tail of its stderr output.
+type process struct {
+	cmd    *exec.Cmd
+	stderr tail
+	done   chan struct{}
+	err    error
+}
+
+// startProcess starts the given command, capturing the tail of its stderr
+// output in addition to sending it to any existing cmd.Stderr.
+func startProcess(cmd *exec.Cmd) (*process, error) {
+	p := &process{cmd: cmd, done: make(chan struct{})}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = &p.stderr
+	} else {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &p.stderr)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		p.err = cmd.Wait()
+
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// Exited waits for the process to exit, returning a *StatterExitedError if it
+// did not exit cleanly. If it does not exit in time, it is killed; if it still
+// does not exit, the returned error will wrap errNotExited.
+func (p *process) Exited() error {
+	select {
+	case <-p.done:
+	case <-time.After(exitWait):
+		p.Kill() //nolint:errcheck
+
+		select {
+		case <-p.done:
+		case <-time.After(exitWait):
+			return &StatterExitedError{ExitCode: -1, Signal: syscall.SIGKILL, Stderr: p.stderr.String(), Err: errNotExited}
+		}
+	}
+
+	if p.err == nil {
+		return nil
+	}
+
+	e := &StatterExitedError{ExitCode: -1, Stderr: p.stderr.String(), Err: p.err}
+
+	if state := p.cmd.ProcessState; state != nil {
+		e.ExitCode = state.ExitCode()
+
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			e.Signal = status.Signal()
+		}
+	}
+
+	return e
+}
+
+// Kill kills the process.
+func (p *process) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+// Exited waits for the statter behind the given net.Conn or walker to exit,
+// returning a *StatterExitedError if it did not exit cleanly.
+func Exited(c any) error {
+	p, ok := c.(interface{ Exited() error })
+	if !ok {
+		return nil
+	}
+
+	return p.Exited()
+}
+
+// Kill kills the statter behind the given net.Conn or walker.
+func Kill(c any) error {
+	p, ok := c.(interface{ Kill() error })
+	if !ok {
+		return nil
+	}
+
+	return p.Kill()
+}
+
+// ExitError returns the *StatterExitedError for the statter behind the given
+// net.Conn or walker if err shows that the pipe to it has died and the statter
+// did not exit cleanly; otherwise, err is returned unchanged.
+func ExitError(c any, err error) error {
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	if exitErr := Exited(c); exitErr != nil {
+		return exitErr
+	}
+
+	return err
+}
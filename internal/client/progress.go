@@ -0,0 +1,213 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"encoding/binary"
+	"maps"
+	"slices"
+	"syscall"
+	"time"
+)
+
+const (
+	progressDirsStart    = 0
+	progressEntriesStart = 8
+	progressErrorsStart  = 16
+	progressBytesStart   = 24
+	progressElapsedStart = 32
+	progressSize         = 40
+
+	errnoCountSize = 12
+)
+
+// WalkProgress contains the running totals of a walk.
+type WalkProgress struct {
+	// Dirs is the number of directories that have been completely walked.
+	Dirs uint64
+
+	// Entries is the number of entries output.
+	Entries uint64
+
+	// Errors is the number of non-fatal errors output.
+	Errors uint64
+
+	// Bytes is the total apparent size of the regular files output; sizes are
+	// only known when the walk was run with WalkOptions.Stat, and hardlinks
+	// are only counted once when it was run with WalkOptions.Hardlinks.
+	Bytes uint64
+
+	// Elapsed is the time since the walk started.
+	Elapsed time.Duration
+}
+
+// WalkSummary contains the totals of a walk that completed, along with the
+// number of errors seen for each error number; errors without an error number
+// are only included in the Errors total.
+type WalkSummary struct {
+	WalkProgress
+
+	Errnos map[syscall.Errno]uint64
+}
+
+func (p *WalkProgress) append(buf []byte) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, p.Dirs)
+	buf = binary.LittleEndian.AppendUint64(buf, p.Entries)
+	buf = binary.LittleEndian.AppendUint64(buf, p.Errors)
+	buf = binary.LittleEndian.AppendUint64(buf, p.Bytes)
+
+	return binary.LittleEndian.AppendUint64(buf, uint64(p.Elapsed)) //nolint:gosec
+}
+
+func readProgress(buf []byte) (*WalkProgress, error) {
+	if len(buf) < progressSize {
+		return nil, ErrInvalidResponse
+	}
+
+	return &WalkProgress{
+		Dirs:    binary.LittleEndian.Uint64(buf[progressDirsStart:]),
+		Entries: binary.LittleEndian.Uint64(buf[progressEntriesStart:]),
+		Errors:  binary.LittleEndian.Uint64(buf[progressErrorsStart:]),
+		Bytes:   binary.LittleEndian.Uint64(buf[progressBytesStart:]),
+		Elapsed: time.Duration(binary.LittleEndian.Uint64(buf[progressElapsedStart:])), //nolint:gosec
+	}, nil
+}
+
+func (s *WalkSummary) append(buf []byte) []byte {
+	buf = s.WalkProgress.append(buf)
+
+	for _, errno := range slices.Sorted(maps.Keys(s.Errnos)) {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(errno))
+		buf = binary.LittleEndian.AppendUint64(buf, s.Errnos[errno])
+	}
+
+	return buf
+}
+
+func readSummary(buf []byte) (*WalkSummary, error) {
+	p, err := readProgress(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = buf[progressSize:]
+	if len(buf)%errnoCountSize != 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	s := &WalkSummary{WalkProgress: *p, Errnos: make(map[syscall.Errno]uint64, len(buf)/errnoCountSize)}
+
+	for ; len(buf) > 0; buf = buf[errnoCountSize:] {
+		s.Errnos[syscall.Errno(binary.LittleEndian.Uint32(buf))] = binary.LittleEndian.Uint64(buf[4:])
+	}
+
+	return s, nil
+}
+
+// counters keeps track of the totals of a walk.
+type counters struct {
+	start  time.Time
+	dirs   uint64
+	total  uint64
+	errors uint64
+	bytes  uint64
+	errnos map[syscall.Errno]uint64
+}
+
+// entry counts the given entry, only counting its size if it isn't a hardlink
+// of an entry that has already been counted.
+func (c *counters) entry(e *entry, hardlink bool) {
+	c.total++
+
+	if !hardlink && e.stat != nil && e.stat.Mode&syscall.S_IFMT == syscall.S_IFREG {
+		c.bytes += uint64(e.stat.Size) //nolint:gosec
+	}
+}
+
+// error counts an error, along with its error number, if it has one.
+func (c *counters) error(errno syscall.Errno) {
+	c.errors++
+
+	if errno == 0 {
+		return
+	}
+
+	if c.errnos == nil {
+		c.errnos = make(map[syscall.Errno]uint64)
+	}
+
+	c.errnos[errno]++
+}
+
+func (c *counters) progress() *WalkProgress {
+	return &WalkProgress{
+		Dirs:    c.dirs,
+		Entries: c.total,
+		Errors:  c.errors,
+		Bytes:   c.bytes,
+		Elapsed: time.Since(c.start),
+	}
+}
+
+func (c *counters) summary() *WalkSummary {
+	return &WalkSummary{WalkProgress: *c.progress(), Errnos: maps.Clone(c.errnos)}
+}
+
+// reportProgress writes a progress record every interval, until the returned
+// function is called.
+func (w *walkWriter) reportProgress(interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.mu.Lock()
+				p := w.counters.progress()
+				w.mu.Unlock()
+
+				w.writeControl(controlProgress, string(p.append(nil))) //nolint:errcheck
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,141 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+const (
+	listingErrStart   = 0
+	listingCountStart = 4
+	listingHeaderSize = 8
+
+	entryLenStart   = 0
+	entryTypeStart  = 2
+	entryHeaderSize = 6
+)
+
+// Entry contains the name and type bits of a single entry read from a
+// directory.
+type Entry struct {
+	Name string
+	Type fs.FileMode
+}
+
+// Readdir takes the net.Conn from CreateStatter and sends a request to read the
+// entries of the directory at the path given, which are returned sorted by
+// name.
+func Readdir(c io.ReadWriter, path string) ([]Entry, error) {
+	if err := writePath(c, path, modeReaddir); err != nil {
+		return nil, err
+	}
+
+	var buf [listingHeaderSize]byte
+
+	if err := readBuf(c, buf[:]); err != nil {
+		return nil, err
+	}
+
+	if errno := binary.LittleEndian.Uint32(buf[listingErrStart:listingCountStart]); errno != 0 {
+		return nil, &os.PathError{
+			Op:   "readdirent",
+			Path: path,
+			Err:  syscall.Errno(errno),
+		}
+	}
+
+	return readEntries(c, binary.LittleEndian.Uint32(buf[listingCountStart:]))
+}
+
+func readEntries(r io.Reader, count uint32) ([]Entry, error) {
+	entries := make([]Entry, count)
+
+	var buf [entryHeaderSize]byte
+
+	for n := range entries {
+		if err := readBuf(r, buf[:]); err != nil {
+			return nil, err
+		}
+
+		name := make([]byte, binary.LittleEndian.Uint16(buf[entryLenStart:entryTypeStart]))
+
+		if err := readBuf(r, name); err != nil {
+			return nil, err
+		}
+
+		entries[n] = Entry{
+			Name: string(name),
+			Type: fs.FileMode(binary.LittleEndian.Uint32(buf[entryTypeStart:])),
+		}
+	}
+
+	return entries, nil
+}
+
+type listing struct {
+	entries []os.DirEntry
+	err     uint32
+}
+
+func readDir(path string, readdirCh chan<- listing) {
+	entries, err := os.ReadDir(path)
+
+	readdirCh <- listing{entries, errNo(err)}
+}
+
+// writeListing writes the error number and the count of entries, followed by
+// the name length, type, and name of each entry, to stdout in a little endian
+// binary format.
+func (s *statter) writeListing(l listing) error {
+	buf := binary.LittleEndian.AppendUint32(s[:0], l.err)
+
+	if l.err != 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, 0)
+	} else {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(l.entries))) //nolint:gosec
+	}
+
+	if _, err := conn.Write(buf); err != nil || l.err != 0 {
+		return err
+	}
+
+	for _, entry := range l.entries {
+		buf = binary.LittleEndian.AppendUint16(s[:0], uint16(len(entry.Name()))) //nolint:gosec
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(entry.Type()))
+		buf = append(buf, entry.Name()...)
+
+		if _, err := conn.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"syscall"
+	"time"
+)
+
+// markUnchanged hides any of the given entries whose mtime and ctime are not
+// after the given time, and marks directories whose mtime is after it as
+// possibly having had entries removed.
+func markUnchanged(entries []entry, since time.Time) {
+	for n := range entries {
+		if e := &entries[n]; e.stat != nil {
+			e.hidden, e.deletionHint = changedSince(e.hidden, e.stat, since)
+		}
+	}
+}
+
+// changedSince returns whether an entry with the given stat data should be
+// hidden, because it is already hidden or has not changed since the given
+// time, and whether it is a directory that has been modified since then.
+func changedSince(hidden bool, stat *syscall.Stat_t, since time.Time) (bool, bool) {
+	mtime := time.Unix(stat.Mtim.Unix())
+	ctime := time.Unix(stat.Ctim.Unix())
+	modified := mtime.After(since)
+
+	return hidden || !modified && !ctime.After(since),
+		modified && stat.Mode&syscall.S_IFMT == syscall.S_IFDIR
+}
@@ -28,7 +28,6 @@ package client
 import (
 	"encoding/binary"
 	"errors"
-	"flag"
 	"io"
 	"io/fs"
 	"os"
@@ -54,8 +53,9 @@ const modeLengthSize = 3
 var (
 	conn io.ReadWriter = readWriter{Reader: os.Stdin, WriteCloser: os.Stdout} //nolint:gochecknoglobals
 
-	ErrTimeout     = errors.New("timeout")
-	ErrInvalidMode = errors.New("invalid mode")
+	ErrTimeout         = errors.New("timeout")
+	ErrInvalidMode     = errors.New("invalid mode")
+	ErrInvalidResponse = errors.New("invalid response")
 
 	stat = os.Lstat //nolint:gochecknoglobals
 )
@@ -76,15 +76,13 @@ func writePath(w io.Writer, path string, m mode) error {
 	buf[0] = byte(m)
 
 	_, err := w.Write(binary.LittleEndian.AppendUint16(buf[:1], uint16(len(path)))) //nolint:gosec
-	if err != nil {
-		if errors.Is(err, fs.ErrClosed) {
-			return io.EOF
-		}
-
-		return err
+	if err == nil {
+		_, err = io.WriteString(w, path)
 	}
 
-	_, err = io.WriteString(w, path)
+	if errors.Is(err, fs.ErrClosed) || errors.Is(err, syscall.EPIPE) {
+		return io.EOF
+	}
 
 	return err
 }
@@ -94,6 +92,12 @@ type fileInfo struct {
 	data syscall.Stat_t
 }
 
+// NewFileInfo returns an fs.FileInfo for the given name and stat data, as would
+// be returned by Stat.
+func NewFileInfo(name string, data *syscall.Stat_t) fs.FileInfo {
+	return &fileInfo{name: name, data: *data}
+}
+
 func (f *fileInfo) Name() string       { return f.name }
 func (f *fileInfo) Size() int64        { return f.data.Size }
 func (f *fileInfo) ModTime() time.Time { return time.Unix(f.data.Mtim.Unix()) }
@@ -178,25 +182,69 @@ type readWriter struct {
 // CreateStatter runs the statter at the given path and returns the net.Conn
 // used to communicate with it.
 func CreateStatter(exe string) (io.ReadWriteCloser, int, error) {
-	cmd := exec.Command(exe) //nolint:noctx
+	return CreateStatterWithOptions(exe, nil)
+}
 
-	in, err := cmd.StdinPipe()
+// CreateStatterWithOptions runs the statter at the given path, spawned
+// according to the given Options, and returns the net.Conn used to communicate
+// with it.
+func CreateStatterWithOptions(exe string, opts *Options) (io.ReadWriteCloser, int, error) {
+	c, err := startStatter(opts.command(exe, nil))
 	if err != nil {
 		return nil, 0, err
 	}
 
+	return c, c.cmd.Process.Pid, nil
+}
+
+// CreateStatterWithPing is like CreateStatterWithOptions, but also returns a
+// separate connection on which the statter will answer pings, even while a
+// request on the main connection is stuck.
+func CreateStatterWithPing(exe string, opts *Options) (io.ReadWriteCloser, io.ReadWriteCloser, error) {
+	local, remote, err := pingPair()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer remote.Close()
+
+	cmd := opts.command(exe, []string{"-ping"})
+	cmd.ExtraFiles = []*os.File{remote}
+
+	c, err := startStatter(cmd)
+	if err != nil {
+		local.Close()
+
+		return nil, nil, err
+	}
+
+	return c, local, nil
+}
+
+func startStatter(cmd *exec.Cmd) (*statterConn, error) {
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
 	out, err := cmd.StdoutPipe()
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, 0, err
+	p, err := startProcess(cmd)
+	if err != nil {
+		return nil, err
 	}
 
-	go cmd.Wait() //nolint:errcheck
+	return &statterConn{readWriter{Reader: out, WriteCloser: in}, p}, nil
+}
 
-	return readWriter{Reader: out, WriteCloser: in}, cmd.Process.Pid, nil
+// statterConn is the net.Conn for a running statter, which can also report why
+// the statter exited.
+type statterConn struct {
+	readWriter
+	*process
 }
 
 type statter [4096]byte
@@ -207,6 +255,8 @@ const (
 	modeStat mode = iota
 	modeHead
 	modeReadlink
+	modePing
+	modeReaddir
 
 	invalidMode
 )
@@ -247,34 +297,32 @@ func (s *statter) writeStat(stat *syscall.Stat_t) error {
 	return err
 }
 
-// Loop infinitely reads a path from stdin, performs a stat with a timeout, and
-// writes the result to stdout.
-func Loop() error {
-	timeout := time.Second
-
-	flag.DurationVar(&timeout, "timeout", timeout, "timeout to wait for stat to finish")
-	flag.Parse()
-
+// Loop infinitely reads a path from stdin, performs a stat with the given
+// timeout, and writes the result to stdout.
+func Loop(timeout time.Duration) error {
 	var s statter
 
 	headPath := make(chan string)
 	statPath := make(chan string)
 	readlinkPath := make(chan string)
+	readdirPath := make(chan string)
 	headCh := make(chan struct{})
 	statCh := make(chan *syscall.Stat_t)
 	readlinkCh := make(chan link)
+	readdirCh := make(chan listing)
 
-	go s.do(statPath, headPath, readlinkPath, statCh, headCh, readlinkCh)
+	go s.do(statPath, headPath, readlinkPath, readdirPath, statCh, headCh, readlinkCh, readdirCh)
 
-	return s.doLoop(timeout, statPath, headPath, readlinkPath, statCh, headCh, readlinkCh)
+	return s.doLoop(timeout, statPath, headPath, readlinkPath, readdirPath, statCh, headCh, readlinkCh, readdirCh)
 }
 
 func (s *statter) doLoop( //nolint:gocyclo,cyclop,funlen
 	timeout time.Duration,
-	statPath, headPath, readlinkPath chan<- string,
+	statPath, headPath, readlinkPath, readdirPath chan<- string,
 	statCh <-chan *syscall.Stat_t,
 	headCh <-chan struct{},
 	readlinkCh <-chan link,
+	readdirCh <-chan listing,
 ) error {
 	for {
 		path, mode, err := s.readPath()
@@ -282,6 +330,14 @@ func (s *statter) doLoop( //nolint:gocyclo,cyclop,funlen
 			return err
 		}
 
+		if mode == modePing {
+			if err := writePong(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		switch mode { //nolint:exhaustive
 		case modeHead:
 			headPath <- path
@@ -289,6 +345,8 @@ func (s *statter) doLoop( //nolint:gocyclo,cyclop,funlen
 			statPath <- path
 		case modeReadlink:
 			readlinkPath <- path
+		case modeReaddir:
+			readdirPath <- path
 		}
 
 		select {
@@ -300,6 +358,8 @@ func (s *statter) doLoop( //nolint:gocyclo,cyclop,funlen
 			err = s.writeStat(stat)
 		case l := <-readlinkCh:
 			err = s.writeLink(l)
+		case l := <-readdirCh:
+			err = s.writeListing(l)
 		}
 
 		if err != nil {
@@ -308,8 +368,8 @@ func (s *statter) doLoop( //nolint:gocyclo,cyclop,funlen
 	}
 }
 
-func (s *statter) do(statPath, headPath, readlinkPath <-chan string,
-	statCh chan<- *syscall.Stat_t, headCh chan<- struct{}, readlinkCh chan<- link,
+func (s *statter) do(statPath, headPath, readlinkPath, readdirPath <-chan string,
+	statCh chan<- *syscall.Stat_t, headCh chan<- struct{}, readlinkCh chan<- link, readdirCh chan<- listing,
 ) {
 	for {
 		select {
@@ -319,6 +379,8 @@ func (s *statter) do(statPath, headPath, readlinkPath <-chan string,
 			s.doHead(path, headCh)
 		case path := <-readlinkPath:
 			readLink(path, readlinkCh)
+		case path := <-readdirPath:
+			readDir(path, readdirCh)
 		}
 	}
 }
@@ -98,6 +98,16 @@ func TestStatLoop(t *testing.T) {
 		So(err.Error(), ShouldEqual, "read /not/a/path: no such file or directory")
 		So(byt, ShouldBeZeroValue)
 
+		So(Ping(local), ShouldBeNil)
+
+		entries, err := Readdir(local, tmp)
+		So(err, ShouldBeNil)
+		So(entries, ShouldResemble, []Entry{{Name: "aFile"}, {Name: "bFile"}, {Name: "cFile"}})
+
+		_, err = Readdir(local, testPathA)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "readdirent "+testPathA+": not a directory")
+
 		s := filepath.Join(tmp, "symlink")
 
 		const symTarget = "/path/to/some/file"
@@ -126,7 +136,7 @@ func TestStatLoop(t *testing.T) {
 
 func startRun(remote io.ReadWriteCloser, errCh chan error) {
 	conn = remote
-	err := Loop()
+	err := Loop(time.Second)
 
 	remote.Close()
 
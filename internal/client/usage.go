@@ -0,0 +1,242 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"cmp"
+	"encoding/binary"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+const (
+	usageUIDStart       = 0
+	usageGIDStart       = 4
+	usageTypeStart      = 8
+	usageAgeStart       = 12
+	usageCountStart     = 16
+	usageBytesStart     = 24
+	usageAllocatedStart = 32
+	usageSize           = 40
+
+	usageGroupsStart = 4
+
+	blockSize = 512
+	day       = 24 * time.Hour
+)
+
+// DefaultAgeBuckets are the age buckets used when aggregating a walk without
+// WalkOptions.AgeBuckets being set.
+var DefaultAgeBuckets = []time.Duration{ //nolint:gochecknoglobals
+	30 * day,
+	90 * day,
+	180 * day,
+	365 * day,
+	2 * 365 * day,
+	3 * 365 * day,
+	5 * 365 * day,
+}
+
+// Usage is the number of entries in a directory with the same owner, type and
+// age, along with their total apparent and allocated sizes.
+type Usage struct {
+	UID uint32
+	GID uint32
+
+	// Type is the type of the entries, as returned by fs.FileMode.Type, which
+	// will be zero for regular files. Directories and symlinks are counted
+	// separately so that the totals of regular files aren't inflated by them.
+	Type fs.FileMode
+
+	// AgeBucket is the index of the first age bucket that the time since the
+	// mtime of the entries is less than, or the number of buckets for entries
+	// older than every bucket.
+	AgeBucket uint32
+
+	Count     uint64
+	Bytes     uint64
+	Allocated uint64
+}
+
+// DirUsage is the usage of the entries of a single directory, not including
+// the contents of its subdirectories, sorted by UID, GID, Type and AgeBucket.
+type DirUsage struct {
+	Path  string
+	Usage []Usage
+}
+
+func (d *DirUsage) append(buf []byte) []byte {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(d.Usage))) //nolint:gosec
+
+	for _, u := range d.Usage {
+		buf = binary.LittleEndian.AppendUint32(buf, u.UID)
+		buf = binary.LittleEndian.AppendUint32(buf, u.GID)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(u.Type))
+		buf = binary.LittleEndian.AppendUint32(buf, u.AgeBucket)
+		buf = binary.LittleEndian.AppendUint64(buf, u.Count)
+		buf = binary.LittleEndian.AppendUint64(buf, u.Bytes)
+		buf = binary.LittleEndian.AppendUint64(buf, u.Allocated)
+	}
+
+	return append(buf, d.Path...)
+}
+
+func readUsage(buf []byte) (*DirUsage, error) {
+	if len(buf) < usageGroupsStart {
+		return nil, ErrInvalidResponse
+	}
+
+	n := uint64(binary.LittleEndian.Uint32(buf))
+	buf = buf[usageGroupsStart:]
+
+	if uint64(len(buf)) < n*usageSize {
+		return nil, ErrInvalidResponse
+	}
+
+	d := &DirUsage{Usage: make([]Usage, n)}
+
+	for i := range d.Usage {
+		d.Usage[i] = Usage{
+			UID:       binary.LittleEndian.Uint32(buf[usageUIDStart:]),
+			GID:       binary.LittleEndian.Uint32(buf[usageGIDStart:]),
+			Type:      fs.FileMode(binary.LittleEndian.Uint32(buf[usageTypeStart:])),
+			AgeBucket: binary.LittleEndian.Uint32(buf[usageAgeStart:]),
+			Count:     binary.LittleEndian.Uint64(buf[usageCountStart:]),
+			Bytes:     binary.LittleEndian.Uint64(buf[usageBytesStart:]),
+			Allocated: binary.LittleEndian.Uint64(buf[usageAllocatedStart:]),
+		}
+		buf = buf[usageSize:]
+	}
+
+	d.Path = string(buf)
+
+	return d, nil
+}
+
+type usageKey struct {
+	uid, gid, age uint32
+	typ           fs.FileMode
+}
+
+// aggregator totals the usage of the entries of each directory in a walk,
+// until the directory is completed.
+type aggregator struct {
+	now     time.Time
+	buckets []time.Duration
+	root    string
+	dirs    map[string]map[usageKey]*Usage
+}
+
+// newAggregator returns an aggregator for the given options, or nil if the walk
+// isn't being aggregated.
+func newAggregator(opts *WalkOptions, now time.Time) *aggregator {
+	if !opts.aggregate() {
+		return nil
+	}
+
+	buckets := opts.AgeBuckets
+	if len(buckets) == 0 {
+		buckets = DefaultAgeBuckets
+	}
+
+	return &aggregator{
+		now:     now,
+		buckets: slices.Sorted(slices.Values(buckets)),
+		dirs:    make(map[string]map[usageKey]*Usage),
+	}
+}
+
+// add adds the given entry, at the given path, to the usage of its directory
+// for its type, only counting its size if it isn't a hardlink of an entry that
+// has already been counted. The root of a walk is not within any walked
+// directory, so isn't counted.
+func (a *aggregator) add(path string, e *entry, hardlink bool) {
+	if path == a.root || e.stat == nil {
+		return
+	}
+
+	dir := parentDir(path)
+
+	groups, ok := a.dirs[dir]
+	if !ok {
+		groups = make(map[usageKey]*Usage)
+		a.dirs[dir] = groups
+	}
+
+	key := usageKey{
+		uid: e.stat.Uid,
+		gid: e.stat.Gid,
+		age: a.ageBucket(time.Unix(e.stat.Mtim.Unix())),
+		typ: e.mode.Type(),
+	}
+
+	u, ok := groups[key]
+	if !ok {
+		u = &Usage{UID: key.uid, GID: key.gid, Type: key.typ, AgeBucket: key.age}
+		groups[key] = u
+	}
+
+	u.Count++
+
+	if !hardlink {
+		u.Bytes += uint64(e.stat.Size)                   //nolint:gosec
+		u.Allocated += uint64(e.stat.Blocks) * blockSize //nolint:gosec
+	}
+}
+
+func (a *aggregator) ageBucket(mtime time.Time) uint32 {
+	age := a.now.Sub(mtime)
+
+	for n, bucket := range a.buckets {
+		if age < bucket {
+			return uint32(n) //nolint:gosec
+		}
+	}
+
+	return uint32(len(a.buckets)) //nolint:gosec
+}
+
+// complete returns the usage of the directory at the given path, which will no
+// longer be tracked.
+func (a *aggregator) complete(path string) *DirUsage {
+	groups := a.dirs[path]
+
+	delete(a.dirs, path)
+
+	d := &DirUsage{Path: path, Usage: make([]Usage, 0, len(groups))}
+
+	for _, u := range groups {
+		d.Usage = append(d.Usage, *u)
+	}
+
+	slices.SortFunc(d.Usage, func(a, b Usage) int {
+		return cmp.Or(cmp.Compare(a.UID, b.UID), cmp.Compare(a.GID, b.GID), cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.AgeBucket, b.AgeBucket))
+	})
+
+	return d
+}
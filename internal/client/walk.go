@@ -31,13 +31,16 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"iter"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
-
-	"github.com/wtsi-hgi/walk"
 )
 
 const (
@@ -47,42 +50,204 @@ const (
 	pathStart      = 14
 )
 
+// ErrWalk is wrapped by the errors passed to an ErrCallback for walk errors.
+var ErrWalk = errors.New("walk error")
+
+// WalkError is the error passed to an ErrCallback for an error encountered at a
+// path during a walk. It contains the message of the original error, and
+// matches ErrWalk, along with the error number of the original error, if it had
+// one, with errors.Is.
+type WalkError struct {
+	Errno syscall.Errno
+	Msg   string
+}
+
+func (e *WalkError) Error() string {
+	return e.Msg
+}
+
+func (e *WalkError) Unwrap() []error {
+	if e.Errno == 0 {
+		return []error{ErrWalk}
+	}
+
+	return []error{ErrWalk, e.Errno}
+}
+
+// Control records have a zero path length, with the length of their payload in
+// the inode field, and their kind in the type field.
+const (
+	controlError uint32 = iota
+	controlRootStart
+	controlRootEnd
+	controlCheckpoint
+	controlProgress
+	controlSummary
+	controlDirComplete
+	controlUsage
+	controlEntryError
+	controlShard
+	controlFrontier
+)
+
+// Entry records use the type bits of a fs.FileMode, leaving the low bits free
+// for flags describing the record.
+const (
+	// flagStat marks an entry record that is followed by a stat block.
+	flagStat uint32 = 1 << iota
+
+	// flagMount marks a directory that was not walked as it is on a different
+	// filesystem.
+	flagMount
+
+	// flagHardlink marks an entry record that is followed, after any stat
+	// block, by the length and path of the first entry with the same inode.
+	flagHardlink
+
+	// flagDeletionHint marks a directory that has been modified since the
+	// WalkOptions.Since time.
+	flagDeletionHint
+
+	recordFlags = flagStat | flagMount | flagHardlink | flagDeletionHint
+)
+
+const (
+	walkStatDevStart    = 0
+	walkStatModeStart   = 8
+	walkStatNlinkStart  = 12
+	walkStatUIDStart    = 20
+	walkStatGIDStart    = 24
+	walkStatSizeStart   = 28
+	walkStatBlocksStart = 36
+	walkStatAtimeStart  = 44
+	walkStatMtimeStart  = 56
+	walkStatCtimeStart  = 68
+	walkStatSize        = 80
+
+	nsecOffset = 8
+
+	dirCompletePathStart   = 8
+	entryErrorPathLenStart = 4
+	entryErrorPathStart    = 6
+)
+
 type walker struct {
 	*bufio.Reader
-	cmd *exec.Cmd
+	*process
+	out *os.File
 }
 
 func (w *walker) Close() error {
-	go w.cmd.Wait() //nolint:errcheck
+	err := w.Kill()
+
+	w.out.Close()
 
-	return w.cmd.Process.Kill()
+	return err
 }
 
-// CreateWalker starts a file walk for the given path, using the given statter
+// CreateWalker starts a file walk for the given roots, using the given statter
 // executable.
-func CreateWalker(exe, path string) (io.ReadCloser, error) {
-	cmd := exec.Command(exe, path) //nolint:noctx
+func CreateWalker(exe string, roots []string) (io.ReadCloser, error) {
+	return CreateWalkerWithOptions(exe, roots, nil)
+}
 
-	cmd.Stderr = os.Stderr
+// CreateWalkerWithOptions starts a file walk for the given roots, using the
+// given statter executable spawned according to the given Options.
+func CreateWalkerWithOptions(exe string, roots []string, opts *Options) (io.ReadCloser, error) {
+	if len(roots) == 0 {
+		return nil, fs.ErrInvalid
+	}
 
-	out, err := cmd.StdoutPipe()
+	return startWalker(opts.command(exe, opts.walkArgs(), append([]string{"--"}, roots...)...))
+}
+
+// CreateStreamWalker starts a streaming walk, using the given statter
+// executable spawned according to the given Options; see WalkOptions.Stream.
+// Roots written, one per line, to the returned writer are walked as soon as
+// they have been written, with the records of the walk being read from the
+// returned reader, and the walk ends once the writer is closed.
+func CreateStreamWalker(exe string, opts *Options) (io.WriteCloser, io.ReadCloser, error) {
+	var o Options
+
+	if opts != nil {
+		o = *opts
+	}
+
+	o.Walk.Stream = true
+	cmd := o.command(exe, o.walkArgs())
+
+	roots, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := startWalker(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return roots, w, nil
+}
+
+func startWalker(cmd *exec.Cmd) (*walker, error) {
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	// A pipe is used instead of cmd.StdoutPipe, as that would be closed once
+	// the process exits, possibly before the final records have been read.
+	out, in, err := os.Pipe()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cmd.Start(); err != nil {
+	cmd.Stdout = in
+
+	p, err := startProcess(cmd)
+	in.Close()
+
+	if err != nil {
+		out.Close()
+
 		return nil, err
 	}
 
-	return &walker{bufio.NewReader(out), cmd}, nil
+	return &walker{bufio.NewReader(out), p, out}, nil
 }
 
 // Dirent contains information for a single path entry discovered during the
 // walk.
+//
+// Unless the walk was run with WalkOptions.Stat, Mode will only contain the
+// type bits, and the stat fields will be zero.
+//
+// MountBoundary is set for directories that were not walked because they are
+// on a different filesystem, when the walk was run with
+// WalkOptions.OneFilesystem.
+//
+// HardlinkOf is set, when the walk was run with WalkOptions.Hardlinks, to the
+// path of the first entry that shares this entry's inode.
+//
+// DeletionHint is set, when the walk was run with WalkOptions.Since, for
+// directories that have been modified since that time, and so may have had
+// entries removed.
 type Dirent struct {
-	Path  string
-	Mode  fs.FileMode
-	Inode uint64
+	Path          string
+	Mode          fs.FileMode
+	Inode         uint64
+	MountBoundary bool
+	HardlinkOf    string
+	DeletionHint  bool
+
+	Dev    uint64
+	Nlink  uint64
+	UID    uint32
+	GID    uint32
+	Size   int64
+	Blocks int64
+	Atime  time.Time
+	Mtime  time.Time
+	Ctime  time.Time
 }
 
 // PathCallback is a function that can receive DirEnts.
@@ -92,10 +257,56 @@ type PathCallback func(entry *Dirent) error
 // during the walk.
 type ErrCallback func(string, error) error
 
+// WalkHandlers contains optional callbacks for the records of a walk other
+// than entries and errors.
+type WalkHandlers struct {
+	// RootStart is called with the path of each root, before any of its
+	// entries are passed to the PathCallback.
+	RootStart func(root string) error
+
+	// RootEnd is called with the path of each root, once its walk has
+	// completed.
+	RootEnd func(root string) error
+
+	// Checkpoint is called with each checkpoint emitted by the walk, which can
+	// be set as WalkOptions.Resume to resume the walk from that point.
+	Checkpoint func(checkpoint string) error
+
+	// Progress is called with the running totals of the walk, every
+	// WalkOptions.ProgressInterval.
+	Progress func(progress *WalkProgress) error
+
+	// Summary is called with the final totals once the walk has completed.
+	Summary func(summary *WalkSummary) error
+
+	// DirComplete is called, when WalkOptions.DirComplete is set, with the
+	// path of each directory once it and all of its contents have been
+	// output, along with the number of its entries that were output.
+	DirComplete func(path string, entries uint64) error
+
+	// Usage is called, when WalkOptions.Aggregate is set, with the totals for
+	// each directory once its entries have been walked.
+	Usage func(usage *DirUsage) error
+
+	// Shard is called, when WalkOptions.Shards is set, with each planned
+	// shard.
+	Shard func(shard *Shard) error
+
+	// Frontier is called, when WalkOptions.Frontier is set, with the path of
+	// each directory that was not read because it is at WalkOptions.MaxDepth.
+	Frontier func(path string) error
+}
+
 // ReaddirEnt will read a single directory entry from the given Reader and pass
 // either a DirEnt to the PathCallback or the path and an error to the
 // ErrCallback.
 func ReadDirEnt(r io.ReadCloser, cb PathCallback, errCB ErrCallback) error {
+	return ReadRecord(r, cb, errCB, nil)
+}
+
+// ReadRecord acts like ReadDirEnt, but also passes any control records to the
+// matching callback of the given WalkHandlers, which may be nil.
+func ReadRecord(r io.ReadCloser, cb PathCallback, errCB ErrCallback, h *WalkHandlers) error {
 	var buf [14]byte
 
 	if err := readBuf(r, buf[:]); err != nil {
@@ -104,13 +315,118 @@ func ReadDirEnt(r io.ReadCloser, cb PathCallback, errCB ErrCallback) error {
 
 	pathLen := binary.LittleEndian.Uint16(buf[:walkInodeStart])
 	if pathLen == 0 {
-		return readError(r, binary.LittleEndian.Uint16(buf[2:]))
+		return readControl(r, binary.LittleEndian.Uint64(buf[walkInodeStart:]),
+			binary.LittleEndian.Uint32(buf[typeStart:]), errCB, h)
+	}
+
+	return readDirEnt(r, pathLen, &buf, cb)
+}
+
+func readControl(r io.Reader, payloadLen uint64, kind uint32, errCB ErrCallback, h *WalkHandlers) error {
+	payload := make([]byte, payloadLen)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
 	}
 
-	return readDirEnt(r, pathLen, &buf, cb, errCB)
+	if h == nil {
+		h = new(WalkHandlers)
+	}
+
+	switch kind {
+	case controlError:
+		return errors.New(string(payload)) //nolint:err113
+	case controlRootStart:
+		return callHandler(h.RootStart, string(payload))
+	case controlRootEnd:
+		return callHandler(h.RootEnd, string(payload))
+	case controlCheckpoint:
+		return callHandler(h.Checkpoint, string(payload))
+	case controlProgress:
+		p, err := readProgress(payload)
+		if err != nil {
+			return err
+		}
+
+		return callHandler(h.Progress, p)
+	case controlSummary:
+		s, err := readSummary(payload)
+		if err != nil {
+			return err
+		}
+
+		return callHandler(h.Summary, s)
+	case controlDirComplete:
+		return readDirComplete(payload, h.DirComplete)
+	case controlUsage:
+		u, err := readUsage(payload)
+		if err != nil {
+			return err
+		}
+
+		return callHandler(h.Usage, u)
+	case controlEntryError:
+		return readEntryError(payload, errCB)
+	case controlShard:
+		s, err := readShard(payload)
+		if err != nil {
+			return err
+		}
+
+		return callHandler(h.Shard, s)
+	case controlFrontier:
+		return callHandler(h.Frontier, string(payload))
+	}
+
+	return ErrInvalidResponse
 }
 
-func readDirEnt(r io.Reader, pl uint16, buf *[14]byte, cb PathCallback, errCB ErrCallback) error {
+// readDirComplete passes the path and entry count from the payload of a
+// directory complete record to the given handler.
+func readDirComplete(payload []byte, fn func(string, uint64) error) error {
+	if len(payload) < dirCompletePathStart {
+		return ErrInvalidResponse
+	}
+
+	if fn == nil {
+		return nil
+	}
+
+	return fn(string(payload[dirCompletePathStart:]), binary.LittleEndian.Uint64(payload))
+}
+
+// readEntryError passes the path from the payload of an entry error record to
+// the given callback, along with a *WalkError containing the error number and
+// message.
+func readEntryError(payload []byte, errCB ErrCallback) error {
+	if len(payload) < entryErrorPathStart {
+		return ErrInvalidResponse
+	}
+
+	pathEnd := entryErrorPathStart + int(binary.LittleEndian.Uint16(payload[entryErrorPathLenStart:]))
+	if len(payload) < pathEnd {
+		return ErrInvalidResponse
+	}
+
+	return errCB(string(payload[entryErrorPathStart:pathEnd]), &WalkError{
+		Errno: syscall.Errno(binary.LittleEndian.Uint32(payload)),
+		Msg:   string(payload[pathEnd:]),
+	})
+}
+
+func callHandler[T any](fn func(T) error, v T) error {
+	if fn == nil {
+		return nil
+	}
+
+	return fn(v)
+}
+
+func readDirEnt(r io.Reader, pl uint16, buf *[14]byte, cb PathCallback) error {
 	pathBuf := make([]byte, pl)
 
 	_, err := io.ReadFull(r, pathBuf)
@@ -122,82 +438,435 @@ func readDirEnt(r io.Reader, pl uint16, buf *[14]byte, cb PathCallback, errCB Er
 		return err
 	}
 
-	path := unsafe.String(unsafe.SliceData(pathBuf), pl)
-	inode := binary.LittleEndian.Uint64(buf[walkInodeStart:typeStart])
 	other := binary.LittleEndian.Uint32(buf[typeStart:])
+	de := &Dirent{
+		Path:          unsafe.String(unsafe.SliceData(pathBuf), pl),
+		Mode:          fs.FileMode(other &^ recordFlags),
+		Inode:         binary.LittleEndian.Uint64(buf[walkInodeStart:typeStart]),
+		MountBoundary: other&flagMount != 0,
+		DeletionHint:  other&flagDeletionHint != 0,
+	}
 
-	if inode == 0 {
-		return errCB(path, syscall.Errno(other))
+	if other&flagStat != 0 {
+		if err := readWalkStat(r, de); err != nil {
+			return err
+		}
 	}
 
-	return cb(&Dirent{
-		Path:  path,
-		Mode:  fs.FileMode(other),
-		Inode: inode,
-	})
+	if other&flagHardlink != 0 {
+		if de.HardlinkOf, err = readHardlink(r); err != nil {
+			return err
+		}
+	}
+
+	return cb(de)
+}
+
+// readHardlink reads the length prefixed path of the first entry sharing an
+// inode.
+func readHardlink(r io.Reader) (string, error) {
+	var buf [2]byte
+
+	if err := readBuf(r, buf[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		return "", err
+	}
+
+	path := make([]byte, binary.LittleEndian.Uint16(buf[:]))
+
+	if _, err := io.ReadFull(r, path); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		return "", err
+	}
+
+	return string(path), nil
 }
 
-func readError(r io.Reader, errLen uint16) error {
-	errBuf := make([]byte, errLen)
+// readWalkStat reads a stat block into the given Dirent.
+func readWalkStat(r io.Reader, de *Dirent) error {
+	var buf [walkStatSize]byte
+
+	if err := readBuf(r, buf[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
 
-	if _, err := io.ReadFull(r, errBuf); err != nil {
 		return err
 	}
 
-	return errors.New(unsafe.String(unsafe.SliceData(errBuf), errLen)) //nolint:err113
+	de.Dev = binary.LittleEndian.Uint64(buf[walkStatDevStart:])
+	de.Mode = modeFromStat(binary.LittleEndian.Uint32(buf[walkStatModeStart:]))
+	de.Nlink = binary.LittleEndian.Uint64(buf[walkStatNlinkStart:])
+	de.UID = binary.LittleEndian.Uint32(buf[walkStatUIDStart:])
+	de.GID = binary.LittleEndian.Uint32(buf[walkStatGIDStart:])
+	de.Size = int64(binary.LittleEndian.Uint64(buf[walkStatSizeStart:]))     //nolint:gosec
+	de.Blocks = int64(binary.LittleEndian.Uint64(buf[walkStatBlocksStart:])) //nolint:gosec
+	de.Atime = readTime(buf[walkStatAtimeStart:])
+	de.Mtime = readTime(buf[walkStatMtimeStart:])
+	de.Ctime = readTime(buf[walkStatCtimeStart:])
+
+	return nil
+}
+
+func readTime(buf []byte) time.Time {
+	return time.Unix(
+		int64(binary.LittleEndian.Uint64(buf)),              //nolint:gosec
+		int64(binary.LittleEndian.Uint32(buf[nsecOffset:])), //nolint:gosec
+	)
+}
+
+// modeFromStat converts a stat mode to a fs.FileMode.
+func modeFromStat(mode uint32) fs.FileMode {
+	return (&fileInfo{data: syscall.Stat_t{Mode: mode}}).Mode()
 }
 
 // walkWriter provides the functions required for a directory walk.
 type walkWriter struct {
-	mu  sync.Mutex
-	buf [4096 + pathStart]byte
+	mu         sync.Mutex
+	buf        [pathStart + 4096 + walkStatSize + 2 + 4096]byte
+	counters   counters
+	checkpoint *checkpointer
+	links      map[fileID]string
+	opts       *WalkOptions
+	usage      *aggregator
+}
+
+// Walk walks the given roots, according to the given options, writing the
+// entries and errors to stdout, with each root surrounded by start and end
+// records, and a summary record once the walk has completed.
+//
+// Roots are walked in lexical order, and any root that is the same as, or
+// within, another root is skipped, unless it is within a skipped directory.
+func Walk(roots []string, opts *WalkOptions) {
+	walkRoots(func(yield func(string, error) bool) {
+		for _, root := range dedupRoots(roots, opts.skip()) {
+			if !yield(root, nil) {
+				return
+			}
+		}
+	}, opts)
 }
 
-func Walk(path string) {
-	var w walkWriter
+// WalkStream acts like Walk, but walks each root read, one per line, from the
+// given reader as soon as it has been read, in the order read, ending the walk
+// once the reader is exhausted; see WalkOptions.Stream.
+func WalkStream(r io.Reader, opts *WalkOptions) {
+	if opts.resume() != "" || opts.shards() > 0 {
+		new(walkWriter).WriteError(fs.ErrInvalid)
 
-	if err := walk.New(w.PathCallback, true, false).Walk(path, w.ErrCallback); err != nil {
-		w.WriteError(err)
+		return
 	}
+
+	walkRoots(func(yield func(string, error) bool) {
+		s := bufio.NewScanner(r)
+
+		for s.Scan() {
+			if line := s.Text(); line != "" && !yield(line, nil) {
+				return
+			}
+		}
+
+		if err := s.Err(); err != nil {
+			yield("", err)
+		}
+	}, opts)
 }
 
-// PathCallback is called for each Dirent discovered, writing the path length,
-// inode, and entry type to stdout, in little endian format,
-// followed by the path.
-func (w *walkWriter) PathCallback(entry *walk.Dirent) error {
+// walkRoots walks the given roots, stopping with a fatal error if the sequence
+// yields an error.
+func walkRoots(roots iter.Seq2[string, error], opts *WalkOptions) {
+	now := time.Now()
+	w := walkWriter{counters: counters{start: now}, opts: opts, usage: newAggregator(opts, now)}
+
+	if opts.resume() != "" && opts.order() != OrderLexical {
+		w.WriteError(ErrInvalidOrder)
+
+		return
+	}
+
+	w.checkpoint = newCheckpointer(opts, w.writeControl)
+	stop := w.reportProgress(opts.progressInterval())
+
+	for root, err := range roots {
+		if err == nil {
+			err = w.walkRoot(root, opts)
+		}
+
+		if err != nil {
+			stop()
+			w.WriteError(err)
+
+			return
+		}
+	}
+
+	stop()
+	w.writeControl(controlSummary, string(w.counters.summary().append(nil))) //nolint:errcheck
+}
+
+func (w *walkWriter) walkRoot(root string, opts *WalkOptions) error {
+	if resumeSkip(opts.resume(), rootPath(root)) {
+		return nil
+	}
+
+	if err := w.writeControl(controlRootStart, rootPath(root)); err != nil {
+		return err
+	}
+
+	if w.usage != nil {
+		w.usage.root = rootPath(root)
+	}
+
+	var frontierCB frontierCallback
+
+	if opts.frontier() {
+		frontierCB = w.FrontierCallback
+	}
+
+	if err := walkDir(root, opts, w.PathCallback, w.ErrCallback, w.DirCallback, frontierCB); err != nil {
+		if !w.rootError(err) {
+			return err
+		}
+	}
+
+	return w.writeControl(controlRootEnd, rootPath(root))
+}
+
+// rootError passes the given error to the error callback, against the root it
+// is for, returning false if it is not a *RootError, in which case the walk
+// can't continue.
+func (w *walkWriter) rootError(err error) bool {
+	var rootErr *RootError
+
+	if !errors.As(err, &rootErr) {
+		return false
+	}
+
+	w.ErrCallback(rootPath(rootErr.Root), rootErr.Err)
+
+	return true
+}
+
+// rootPath returns the cleaned root with a trailing slash, as it will appear
+// as the first entry of its walk.
+func rootPath(root string) string {
+	return strings.TrimSuffix(filepath.Clean(root), "/") + "/"
+}
+
+// parentDir returns the path, with a trailing slash, of the directory
+// containing the entry at the given path.
+func parentDir(path string) string {
+	return path[:strings.LastIndexByte(strings.TrimSuffix(path, "/"), '/')+1]
+}
+
+// dedupRoots returns the given roots sorted, without any that are the same as,
+// or within, another root, unless they are within a directory skipped by the
+// walk of that root.
+func dedupRoots(roots []string, skip Paths) []string {
+	roots = slices.Clone(roots)
+
+	slices.SortFunc(roots, func(a, b string) int {
+		return strings.Compare(rootPath(a), rootPath(b))
+	})
+
+	var parents []string
+
+	return slices.DeleteFunc(roots, func(root string) bool {
+		path := rootPath(root)
+
+		for len(parents) > 0 && !strings.HasPrefix(path, parents[len(parents)-1]) {
+			parents = parents[:len(parents)-1]
+		}
+
+		for _, parent := range parents {
+			if !skippedWithin(parent, path, skip) {
+				return true
+			}
+		}
+
+		parents = append(parents, path)
+
+		return false
+	})
+}
+
+// skippedWithin returns true if the given path is within one of the given
+// skipped directories that is itself within the given root.
+func skippedWithin(root, path string, skip Paths) bool {
+	for _, dir := range skip {
+		dir = rootPath(dir)
+
+		if dir != root && strings.HasPrefix(dir, root) && strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PathCallback is called for each entry discovered, writing the path length,
+// inode, and entry type and flags to stdout, in little endian format,
+// followed by the path, then, if the entry was stat'd, the stat block, and, if
+// the entry is a hardlink of an earlier entry, the length and path of that
+// entry. When aggregating, the entry is instead added to the usage of its
+// directory.
+func (w *walkWriter) PathCallback(path string, e *entry) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	buf := entry.AppendTo(w.buf[:pathStart])
-	binary.LittleEndian.AppendUint16(w.buf[:lenStart], uint16(len(buf))-pathStart) //nolint:gosec
-	binary.LittleEndian.AppendUint64(w.buf[:walkInodeStart], entry.Inode)
-	binary.LittleEndian.AppendUint32(w.buf[:typeStart], uint32(entry.Type()))
+	first := w.hardlinkOf(path, e)
+
+	w.counters.entry(e, first != "")
+
+	if w.usage != nil {
+		w.usage.add(path, e, first != "")
+
+		return nil
+	}
+
+	buf := append(w.buf[:pathStart], path...)
+	flags := uint32(0)
+
+	if e.stat != nil {
+		flags |= flagStat
+		buf = appendWalkStat(buf, e.stat)
+	}
+
+	if e.mount {
+		flags |= flagMount
+	}
+
+	if e.deletionHint {
+		flags |= flagDeletionHint
+	}
+
+	if first != "" {
+		flags |= flagHardlink
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(first))) //nolint:gosec
+		buf = append(buf, first...)
+	}
+
+	binary.LittleEndian.PutUint16(buf[lenStart:], uint16(len(path))) //nolint:gosec
+	binary.LittleEndian.PutUint64(buf[walkInodeStart:], e.inode)
+	binary.LittleEndian.PutUint32(buf[typeStart:], uint32(e.mode)|flags)
 
 	_, err := conn.Write(buf)
 
 	return err
 }
 
-// ErrCallback is called for each non-fatal error, writing the path length, a
-// zero inode, and the error number to stdout, in little endian format, followed
-// by the path.
+// DirCallback is called for each directory once it has been completely walked,
+// writing a usage record when aggregating, a directory complete record, if
+// requested, and a checkpoint record when one is due.
+func (w *walkWriter) DirCallback(path string, entries uint64) error {
+	var usage *DirUsage
+
+	w.mu.Lock()
+	w.counters.dirs++
+
+	if w.usage != nil {
+		usage = w.usage.complete(path)
+	}
+
+	w.mu.Unlock()
+
+	if usage != nil {
+		if err := w.writeControl(controlUsage, string(usage.append(nil))); err != nil {
+			return err
+		}
+	}
+
+	if w.opts.dirComplete() {
+		payload := binary.LittleEndian.AppendUint64(make([]byte, 0, dirCompletePathStart+len(path)), entries)
+
+		if err := w.writeControl(controlDirComplete, string(append(payload, path...))); err != nil {
+			return err
+		}
+	}
+
+	return w.checkpoint.completed(path)
+}
+
+// FrontierCallback is called for each directory that is not read because it is
+// at the maximum depth, writing a frontier record containing its path.
+func (w *walkWriter) FrontierCallback(path string) error {
+	return w.writeControl(controlFrontier, path)
+}
+
+// appendWalkStat appends the device, mode, nlink, uid, gid, size, blocks, and
+// access, modification, and change times to the given buffer, in little endian
+// format.
+func appendWalkStat(buf []byte, stat *syscall.Stat_t) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, stat.Dev)
+	buf = binary.LittleEndian.AppendUint32(buf, stat.Mode)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(stat.Nlink)) //nolint:unconvert,nolintlint
+	buf = binary.LittleEndian.AppendUint32(buf, stat.Uid)
+	buf = binary.LittleEndian.AppendUint32(buf, stat.Gid)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(stat.Size))   //nolint:gosec
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(stat.Blocks)) //nolint:gosec
+	buf = appendTime(buf, stat.Atim)
+	buf = appendTime(buf, stat.Mtim)
+
+	return appendTime(buf, stat.Ctim)
+}
+
+func appendTime(buf []byte, t syscall.Timespec) []byte {
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(t.Sec)) //nolint:gosec,unconvert,nolintlint
+
+	return binary.LittleEndian.AppendUint32(buf, uint32(t.Nsec)) //nolint:gosec
+}
+
+// ErrCallback is called for each non-fatal error, writing an entry error
+// record containing the error number of the error, or zero if it has none, the
+// length of the path, the path, and the error message.
 func (w *walkWriter) ErrCallback(path string, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	binary.LittleEndian.AppendUint16(w.buf[:lenStart], uint16(len(path))) //nolint:gosec
-	binary.LittleEndian.AppendUint64(w.buf[:walkInodeStart], 0)
-	binary.LittleEndian.AppendUint32(w.buf[:typeStart], uint32(err.(syscall.Errno))) //nolint:errcheck,forcetypeassert,errorlint,lll
+	var errno syscall.Errno
+
+	errors.As(err, &errno)
+
+	w.counters.error(errno)
+
+	payload := make([]byte, 0, entryErrorPathStart+len(path))
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(errno))
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(len(path))) //nolint:gosec
 
-	conn.Write(append(w.buf[:pathStart], path...)) //nolint:errcheck
+	w.control(controlEntryError, string(append(append(payload, path...), err.Error()...))) //nolint:errcheck
 }
 
 // WriteError writes fatal errors to stdout.
 func (w *walkWriter) WriteError(err error) {
-	w.buf[0] = 0
-	w.buf[1] = 0
-	errMsg := err.Error()
+	w.writeControl(controlError, err.Error()) //nolint:errcheck
+}
+
+// writeControl writes a control record of the given kind, with the given
+// payload, to stdout.
+func (w *walkWriter) writeControl(kind uint32, payload string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	binary.LittleEndian.AppendUint16(w.buf[:walkInodeStart], uint16(len(errMsg))) //nolint:gosec
-	conn.Write(append(w.buf[:14], errMsg...))                                     //nolint:errcheck
+	return w.control(kind, payload)
+}
+
+// control writes a control record.
+//
+// Must be called with the walkWriter lock held.
+func (w *walkWriter) control(kind uint32, payload string) error {
+	buf := append(w.buf[:pathStart], payload...)
+
+	binary.LittleEndian.PutUint16(buf[lenStart:], 0)
+	binary.LittleEndian.PutUint64(buf[walkInodeStart:], uint64(len(payload)))
+	binary.LittleEndian.PutUint32(buf[typeStart:], kind)
+
+	_, err := conn.Write(buf)
+
+	return err
 }
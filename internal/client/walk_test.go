@@ -32,8 +32,12 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/wtsi-hgi/statter/internal/testhelper"
@@ -56,7 +60,7 @@ func TestWalk(t *testing.T) {
 		Reset(func() { os.Chmod(filepath.Join(tmp, "2"), 0700) }) //nolint:errcheck
 
 		go func() {
-			Walk(tmp)
+			Walk([]string{tmp}, nil)
 			pw.Close()
 		}()
 
@@ -94,6 +98,466 @@ func TestWalk(t *testing.T) {
 	})
 }
 
+func TestWalkErrors(t *testing.T) {
+	Convey("Walk errors are passed on with their messages and any error numbers", t, func() {
+		pr, pw := io.Pipe()
+		conn = &readWriter{WriteCloser: pw}
+
+		tmp := t.TempDir()
+		testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			switch path {
+			case tmp + "/1/":
+				return nil, errors.New("custom failure") //nolint:err113
+			case tmp + "/2/":
+				return nil, &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+			}
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() { scan = scanDir })
+
+		go func() {
+			Walk([]string{tmp}, nil)
+			pw.Close()
+		}()
+
+		var (
+			errs    []error
+			paths   []string
+			summary *WalkSummary
+		)
+
+		for {
+			err := ReadRecord(pr, func(*Dirent) error { return nil }, func(path string, err error) error {
+				paths = append(paths, path)
+				errs = append(errs, err)
+
+				return nil
+			}, &WalkHandlers{Summary: func(s *WalkSummary) error {
+				summary = s
+
+				return nil
+			}})
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			So(err, ShouldBeNil)
+		}
+
+		So(paths, ShouldResemble, []string{tmp + "/1/", tmp + "/2/"})
+		So(len(errs), ShouldEqual, 2)
+		So(errs[0], ShouldWrap, ErrWalk)
+		So(errs[0].Error(), ShouldEqual, "custom failure")
+		So(errs[1], ShouldWrap, ErrWalk)
+		So(errs[1], ShouldWrap, syscall.EACCES)
+		So(errs[1].Error(), ShouldEqual, "open "+tmp+"/2/: permission denied")
+
+		var walkErr *WalkError
+
+		So(errors.As(errs[0], &walkErr), ShouldBeTrue)
+		So(walkErr.Errno, ShouldBeZeroValue)
+		So(errors.As(errs[1], &walkErr), ShouldBeTrue)
+		So(walkErr.Errno, ShouldEqual, syscall.EACCES)
+
+		So(summary, ShouldNotBeNil)
+		So(summary.Errors, ShouldEqual, 2)
+		So(summary.Errnos, ShouldResemble, map[syscall.Errno]uint64{syscall.EACCES: 1})
+	})
+}
+
+func TestWalkFollowOrder(t *testing.T) {
+	Convey("When following symlinks, the first path to a directory in output order is walked", t, func() {
+		tmp := t.TempDir()
+
+		So(os.MkdirAll(filepath.Join(tmp, "a"), 0700), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join(tmp, "b", "real"), 0700), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "b", "real", "f1"), nil, 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "b", "real", "f2"), nil, 0600), ShouldBeNil)
+		So(os.Symlink("../b/real", filepath.Join(tmp, "a", "link")), ShouldBeNil)
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			if path == tmp+"/a/" {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() { scan = scanDir })
+
+		var paths, errs []string
+
+		So(walkDir(tmp, &WalkOptions{FollowSymlinks: true}, func(path string, _ *entry) error {
+			paths = append(paths, path)
+
+			return nil
+		}, func(path string, err error) {
+			errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+		}, nil, nil), ShouldBeNil)
+
+		So(paths, ShouldResemble, []string{
+			tmp + "/", tmp + "/a/", tmp + "/a/link/", tmp + "/a/link/f1", tmp + "/a/link/f2",
+			tmp + "/b/", tmp + "/b/real/",
+		})
+		So(errs, ShouldResemble, []string{tmp + "/b/real/: too many levels of symbolic links"})
+	})
+}
+
+func TestWalkTimeout(t *testing.T) {
+	Convey("Directories that take too long to read are reported with ETIMEDOUT", t, func() {
+		tmp := t.TempDir()
+		paths := append([]string{tmp + "/"}, testhelper.FillDirWithFiles(t, tmp, 2, nil)...)
+
+		hung := make(chan struct{})
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			if path == tmp+"/1/" {
+				<-hung
+			}
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() {
+			close(hung)
+
+			scan = scanDir
+		})
+
+		var (
+			found []string
+			errs  []string
+		)
+
+		So(walkDir(tmp, &WalkOptions{DirTimeout: 100 * time.Millisecond},
+			func(path string, _ *entry) error {
+				found = append(found, path)
+
+				return nil
+			}, func(path string, err error) {
+				errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+			}, nil, nil), ShouldBeNil)
+
+		So(found, ShouldResemble, slices.DeleteFunc(paths, func(path string) bool {
+			return strings.HasPrefix(path, tmp+"/1/") && path != tmp+"/1/"
+		}))
+		So(errs, ShouldResemble, []string{tmp + "/1/: connection timed out"})
+	})
+
+	Convey("Roots that take too long to stat are reported with ETIMEDOUT", t, func() {
+		tmp := t.TempDir()
+		hung := make(chan struct{})
+
+		statRoot = func(path string) (os.FileInfo, error) {
+			<-hung
+
+			return os.Stat(path)
+		}
+
+		Reset(func() {
+			close(hung)
+
+			statRoot = os.Stat
+		})
+
+		err := walkDir(tmp, &WalkOptions{DirTimeout: 100 * time.Millisecond},
+			func(string, *entry) error { return nil }, func(string, error) {}, nil, nil)
+
+		var rootErr *RootError
+
+		So(errors.As(err, &rootErr), ShouldBeTrue)
+		So(rootErr.Root, ShouldEqual, tmp)
+		So(rootErr.Err, ShouldEqual, syscall.ETIMEDOUT)
+	})
+}
+
+func TestWalkConcurrency(t *testing.T) {
+	Convey("With a test directory containing long names", t, func() {
+		tmp := t.TempDir()
+		paths := append([]string{tmp + "/"}, testhelper.FillDirWithFiles(t, tmp, 3, nil)...)
+		long := filepath.Join(tmp, "long")
+
+		So(os.Mkdir(long, 0700), ShouldBeNil)
+
+		paths = append(paths, long+"/")
+
+		for n := range 20 {
+			name := filepath.Join(long, fmt.Sprintf("%02d%s", n, strings.Repeat("a", 250)))
+
+			So(os.WriteFile(name, nil, 0600), ShouldBeNil)
+
+			paths = append(paths, name)
+		}
+
+		var (
+			mu                    sync.Mutex
+			active, most, scanned int
+		)
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			mu.Lock()
+			active++
+			scanned++
+			most = max(most, active)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			defer func() {
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() { scan = scanDir })
+
+		walk := func(opts *WalkOptions) []string {
+			var found []string
+
+			most = 0
+
+			So(walkDir(tmp, opts, func(path string, _ *entry) error {
+				found = append(found, path)
+
+				return nil
+			}, func(string, error) {}, nil, nil), ShouldBeNil)
+
+			return found
+		}
+
+		Convey("You can limit the number of directories read at once", func() {
+			So(walk(&WalkOptions{Walkers: 1}), ShouldResemble, paths)
+			So(most, ShouldEqual, 1)
+
+			So(walk(&WalkOptions{Walkers: 4}), ShouldResemble, paths)
+			So(most, ShouldBeBetweenOrEqual, 1, 4)
+		})
+
+		Convey("Readers do not get too far ahead of the output", func() {
+			var readAhead int
+
+			So(walkDir(tmp, &WalkOptions{Walkers: 1}, func(path string, _ *entry) error {
+				if path == tmp+"/" {
+					time.Sleep(100 * time.Millisecond)
+
+					mu.Lock()
+					readAhead = scanned
+					mu.Unlock()
+				}
+
+				return nil
+			}, func(string, error) {}, nil, nil), ShouldBeNil)
+
+			So(readAhead, ShouldEqual, aheadPerWalker)
+			So(scanned, ShouldEqual, 17)
+		})
+
+		Convey("You can change the size of each directory read", func() {
+			So(walk(&WalkOptions{ReadSize: 1}), ShouldResemble, paths)
+			So(walk(&WalkOptions{ReadSize: maxDirentSize}), ShouldResemble, paths)
+			So(walk(&WalkOptions{ReadSize: 1 << 20}), ShouldResemble, paths)
+		})
+	})
+}
+
+func TestWalkStat(t *testing.T) {
+	Convey("With the Stat option, walk entries include full stat data", t, func() {
+		pr, pw := io.Pipe()
+		conn = &readWriter{WriteCloser: pw}
+
+		tmp := t.TempDir()
+		paths := append([]string{tmp + "/"}, testhelper.FillDirWithFiles(t, tmp, 2, nil)...)
+
+		So(os.WriteFile(filepath.Join(tmp, "1.file"), []byte("some data"), 0600), ShouldBeNil)
+		So(os.Symlink("1", filepath.Join(tmp, "link")), ShouldBeNil)
+
+		paths = append(paths, tmp+"/link")
+
+		go func() {
+			Walk([]string{tmp}, &WalkOptions{Stat: true})
+			pw.Close()
+		}()
+
+		var dirents []*Dirent
+
+		for {
+			err := ReadDirEnt(pr, func(de *Dirent) error {
+				dirents = append(dirents, de)
+
+				return nil
+			}, func(path string, err error) error {
+				return err
+			})
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			So(err, ShouldBeNil)
+		}
+
+		So(len(dirents), ShouldEqual, len(paths))
+
+		for n, de := range dirents {
+			So(de.Path, ShouldEqual, paths[n])
+
+			fi, err := os.Lstat(de.Path)
+			So(err, ShouldBeNil)
+
+			stat := fi.Sys().(*syscall.Stat_t) //nolint:errcheck,forcetypeassert
+
+			So(de.Mode, ShouldEqual, fi.Mode())
+			So(de.Inode, ShouldEqual, stat.Ino)
+			So(de.Dev, ShouldEqual, stat.Dev)
+			So(de.Nlink, ShouldEqual, stat.Nlink)
+			So(de.UID, ShouldEqual, stat.Uid)
+			So(de.GID, ShouldEqual, stat.Gid)
+			So(de.Size, ShouldEqual, stat.Size)
+			So(de.Blocks, ShouldEqual, stat.Blocks)
+			So(de.Mtime, ShouldEqual, time.Unix(stat.Mtim.Unix()))
+			So(de.Ctime, ShouldEqual, time.Unix(stat.Ctim.Unix()))
+			So(de.Atime.IsZero(), ShouldBeFalse)
+		}
+
+		So(dirents[1].Size, ShouldEqual, len("some data"))
+		So(dirents[len(dirents)-1].Mode&fs.ModeSymlink, ShouldNotEqual, 0)
+	})
+}
+
+func TestWalkResume(t *testing.T) {
+	Convey("Given a walk that emits checkpoints", t, func() {
+		tmp := t.TempDir()
+		paths := append([]string{tmp + "/"}, testhelper.FillDirWithFiles(t, tmp, 3, nil)...)
+
+		var (
+			mu   sync.Mutex
+			read []string
+		)
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			mu.Lock()
+			read = append(read, path)
+			mu.Unlock()
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() { scan = scanDir })
+
+		walk := func(opts *WalkOptions) ([]string, []string) {
+			var found, checkpoints []string
+
+			read = nil
+
+			c := newCheckpointer(opts, func(kind uint32, payload string) error {
+				So(kind, ShouldEqual, controlCheckpoint)
+
+				checkpoints = append(checkpoints, payload)
+
+				return nil
+			})
+
+			So(walkDir(tmp, opts, func(path string, _ *entry) error {
+				found = append(found, path)
+
+				return nil
+			}, func(string, error) {}, func(path string, _ uint64) error {
+				return c.completed(path)
+			}, nil), ShouldBeNil)
+
+			return found, checkpoints
+		}
+
+		found, checkpoints := walk(&WalkOptions{CheckpointInterval: time.Nanosecond})
+		So(found, ShouldResemble, paths)
+		So(len(checkpoints), ShouldBeGreaterThan, 1)
+		So(checkpoints[len(checkpoints)-1], ShouldEqual, tmp+"/")
+
+		Convey("You can resume from any checkpoint, skipping completed directories", func() {
+			for _, checkpoint := range checkpoints {
+				found, _ := walk(&WalkOptions{Resume: checkpoint})
+
+				So(append([]string{}, found...), ShouldResemble, slices.DeleteFunc(slices.Clone(paths), func(path string) bool {
+					return resumeDone(checkpoint, path)
+				}))
+
+				for _, dir := range read {
+					So(resumeSkip(checkpoint, dir), ShouldBeFalse)
+					So(dir == checkpoint || strings.HasPrefix(dir, checkpoint), ShouldBeFalse)
+				}
+			}
+		})
+	})
+}
+
+func TestWalkProgress(t *testing.T) {
+	Convey("A walk reports its progress, and ends with a summary", t, func() {
+		pr, pw := io.Pipe()
+		conn = &readWriter{WriteCloser: pw}
+
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		scan = func(path string, buf []byte) ([]entry, error) {
+			if path == tmp+"/1/" {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			return scanDir(path, buf)
+		}
+
+		Reset(func() { scan = scanDir })
+
+		go func() {
+			Walk([]string{tmp}, &WalkOptions{ProgressInterval: 10 * time.Millisecond})
+			pw.Close()
+		}()
+
+		var (
+			progress []*WalkProgress
+			summary  *WalkSummary
+		)
+
+		h := &WalkHandlers{
+			Progress: func(p *WalkProgress) error {
+				progress = append(progress, p)
+
+				return nil
+			},
+			Summary: func(s *WalkSummary) error {
+				summary = s
+
+				return nil
+			},
+		}
+
+		for {
+			err := ReadRecord(pr, func(*Dirent) error { return nil }, func(string, error) error { return nil }, h)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			So(err, ShouldBeNil)
+		}
+
+		So(len(progress), ShouldBeGreaterThan, 1)
+		So(progress[0].Entries, ShouldBeGreaterThan, 0)
+		So(progress[0].Entries, ShouldBeLessThan, len(paths)+1)
+		So(summary, ShouldNotBeNil)
+		So(summary.Entries, ShouldEqual, len(paths)+1)
+		So(summary.Dirs, ShouldEqual, 5)
+		So(summary.Errors, ShouldEqual, 0)
+		So(summary.Errnos, ShouldBeEmpty)
+		So(summary.Elapsed, ShouldBeGreaterThanOrEqualTo, 100*time.Millisecond)
+	})
+}
+
 func makeDirEnt(t *testing.T, path string) *Dirent {
 	t.Helper()
 
@@ -0,0 +1,460 @@
+/*******************************************************************************
+ * Copyright (c) 2026 Genome Research Ltd.
+ *
+ * Author: Michael Woolnough <mw31@sanger.ac.uk>
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included
+ * in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.
+ * IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY
+ * CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT,
+ * TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ ******************************************************************************/
+
+package client
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WalkOptions contains the settings for a walk.
+type WalkOptions struct {
+	// DirTimeout is the maximum time to wait for a single directory to be read,
+	// or for a root to be stat'd; those that take longer are reported with
+	// ETIMEDOUT and the rest of the walk continues. A zero value means no
+	// timeout.
+	DirTimeout time.Duration
+
+	// Stat causes every entry to be stat'd, with the results being included in
+	// the Dirent.
+	Stat bool
+
+	// MaxDepth limits how deep the walk will go, with the entries of the root
+	// directory being at depth 1; directories at the maximum depth are output,
+	// but not read. A zero value means no limit.
+	MaxDepth int
+
+	// Include, if set, limits the output to entries whose names match at least
+	// one of the patterns. Directories that don't match are still walked.
+	Include Patterns
+
+	// Exclude prevents entries whose names match any of the patterns from being
+	// output. Directories that match are still walked.
+	Exclude Patterns
+
+	// Prune prevents directories whose names match any of the patterns from
+	// being output or read.
+	Prune Patterns
+
+	// OneFilesystem stops the walk from descending into directories on other
+	// filesystems; such directories are output marked as mount boundaries.
+	OneFilesystem bool
+
+	// FollowSymlinks causes symlinks to directories to be walked as the
+	// directories they point to. Directories that have already been seen, such
+	// as those forming a cycle, are output as symlinks and reported with ELOOP
+	// instead of being walked again.
+	FollowSymlinks bool
+
+	// CheckpointInterval is the minimum time between checkpoint records, each
+	// of which contains the path of a directory that has been completely
+	// walked. A zero value disables checkpoints.
+	CheckpointInterval time.Duration
+
+	// Resume is a checkpoint from a previous walk of the same roots; entries
+	// that were output before that checkpoint are skipped, and completed
+	// directories are not read again.
+	Resume string
+
+	// ProgressInterval is the time between progress records, each of which
+	// contains the running totals of the walk. A zero value disables progress
+	// records.
+	ProgressInterval time.Duration
+
+	// Hardlinks causes regular files with more than one link to be tracked, so
+	// that each inode is only reported once; later paths to the same inode are
+	// marked as hardlinks of the first.
+	Hardlinks bool
+
+	// Since, if set, limits the output to entries whose mtime or ctime is after
+	// the given time, with directories whose mtime is after it being marked
+	// with a deletion hint, as entries may have been removed from them.
+	// Directories are walked regardless. Setting Since implies Stat.
+	Since time.Time
+
+	// DirComplete causes a record to be output for each directory once it and
+	// all of its contents have been output, containing the number of its
+	// entries that were output.
+	DirComplete bool
+
+	// Order determines the order in which entries are output. Checkpoints are
+	// only emitted, and Resume can only be used, with OrderLexical. With
+	// OrderBreadthFirst, a directory is completed once its own entries have
+	// been output.
+	Order WalkOrder
+
+	// Walkers is the number of directories that are read concurrently. A zero
+	// value will use the default of 16.
+	Walkers int
+
+	// ReadSize is the size, in bytes, of the buffer used for each read of a
+	// directory. A zero value will use the page size, and values too small to
+	// hold any directory entry are increased to that size.
+	ReadSize int
+
+	// Aggregate causes the walk to output, instead of an entry record for each
+	// entry, a usage record for each directory, totalling the number of
+	// entries in it, and their apparent and allocated sizes, for each
+	// combination of owner, group, and age bucket. Setting Aggregate implies
+	// Stat.
+	Aggregate bool
+
+	// AgeBuckets are the upper bounds of the ages, based on the mtime of each
+	// entry, used to group aggregated usage. A nil value will use
+	// DefaultAgeBuckets.
+	AgeBuckets []time.Duration
+
+	// Skip prevents the directories at the given paths from being output or
+	// read.
+	Skip Paths
+
+	// Shards, if set, causes the walk to plan, instead of outputting entries,
+	// up to the given number of shards of roughly equal size, each of which
+	// can be walked separately by walking its roots while skipping its skip
+	// paths. Sizes are estimated from the number of entries within MaxDepth,
+	// or a depth of 3 if MaxDepth isn't set. Directories at that depth can't
+	// be split between shards, and are sized by their direct entries only, so
+	// deep trees may need a larger MaxDepth to be balanced.
+	Shards int
+
+	// Frontier causes a record to be output for each directory that is not
+	// read because it is at MaxDepth, even if its entry is not output.
+	Frontier bool
+
+	// SkipRoots stops the entries of the roots themselves from being output,
+	// for walks of directories whose entries have already been output.
+	SkipRoots bool
+
+	// Stream causes the roots to be read from stdin, one per line, instead of
+	// being given as arguments, with each being walked as soon as it has been
+	// read, in the order read, so that a single statter can be given more work
+	// as it finishes its previous roots. Roots are not sorted or deduplicated,
+	// and the walk ends once stdin is closed. Stream can't be used with Resume
+	// or Shards.
+	Stream bool
+}
+
+// AddFlags registers the walk options as flags on the given FlagSet.
+func (w *WalkOptions) AddFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&w.DirTimeout, "dirtimeout", 0, "timeout to wait for each directory to be read during a walk")
+	fs.BoolVar(&w.Stat, "stat", false, "include full stat data for each entry in a walk")
+	fs.IntVar(&w.MaxDepth, "maxdepth", 0, "maximum depth of a walk")
+	fs.Var(&w.Include, "include", "only output walk entries whose names match the pattern; can be repeated")
+	fs.Var(&w.Exclude, "exclude", "don't output walk entries whose names match the pattern; can be repeated")
+	fs.Var(&w.Prune, "prune", "don't output or read directories whose names match the pattern; can be repeated")
+	fs.BoolVar(&w.OneFilesystem, "xdev", false, "don't descend into directories on other filesystems during a walk")
+	fs.BoolVar(&w.FollowSymlinks, "follow", false, "follow symlinks to directories during a walk")
+	fs.DurationVar(&w.CheckpointInterval, "checkpoint", 0, "minimum interval between walk checkpoints")
+	fs.StringVar(&w.Resume, "resume", "", "checkpoint from which to resume a walk")
+	fs.DurationVar(&w.ProgressInterval, "progress", 0, "interval between walk progress records")
+	fs.BoolVar(&w.Hardlinks, "hardlinks", false, "mark later paths to a hardlinked file as aliases of the first")
+	fs.Func("since", "only output walk entries changed after the given RFC3339 time", func(v string) error {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return err
+		}
+
+		w.Since = t
+
+		return nil
+	})
+	fs.BoolVar(&w.DirComplete, "dircomplete", false, "output a record as each directory of a walk is completed")
+	fs.Var(&w.Order, "order", "order of walk output: lexical, breadth, or dirsfirst")
+	fs.IntVar(&w.Walkers, "walkers", 0, "number of directories read concurrently during a walk")
+	fs.IntVar(&w.ReadSize, "readsize", 0, "size in bytes of the buffer used for each directory read during a walk")
+	fs.BoolVar(&w.Aggregate, "aggregate", false, "output usage totals for each directory instead of walk entries")
+	fs.Func("agebuckets", "comma separated age bucket durations for aggregated walks", func(v string) error {
+		w.AgeBuckets = w.AgeBuckets[:0]
+
+		for bucket := range strings.SplitSeq(v, ",") {
+			d, err := time.ParseDuration(bucket)
+			if err != nil {
+				return err
+			}
+
+			w.AgeBuckets = append(w.AgeBuckets, d)
+		}
+
+		return nil
+	})
+	fs.Var(&w.Skip, "skip", "don't output or read the directory at the given path; can be repeated")
+	fs.IntVar(&w.Shards, "shards", 0, "plan the given number of balanced shards instead of outputting walk entries")
+	fs.BoolVar(&w.Frontier, "frontier", false, "output a record for each directory not read due to the maximum depth")
+	fs.BoolVar(&w.Stream, "stream", false, "walk each root read from stdin as soon as it has been read")
+	fs.BoolVar(&w.SkipRoots, "skiproots", false, "don't output the entries of the walk roots themselves")
+}
+
+// args returns the statter arguments needed to set the walk options.
+func (w *WalkOptions) args() []string {
+	var args []string
+
+	if w.DirTimeout > 0 {
+		args = append(args, "-dirtimeout="+w.DirTimeout.String())
+	}
+
+	if w.Stat {
+		args = append(args, "-stat")
+	}
+
+	if w.MaxDepth > 0 {
+		args = append(args, "-maxdepth="+strconv.Itoa(w.MaxDepth))
+	}
+
+	args = appendPatternArgs(args, "include", w.Include)
+	args = appendPatternArgs(args, "exclude", w.Exclude)
+	args = appendPatternArgs(args, "prune", w.Prune)
+
+	if w.OneFilesystem {
+		args = append(args, "-xdev")
+	}
+
+	if w.FollowSymlinks {
+		args = append(args, "-follow")
+	}
+
+	if w.CheckpointInterval > 0 {
+		args = append(args, "-checkpoint="+w.CheckpointInterval.String())
+	}
+
+	if w.Resume != "" {
+		args = append(args, "-resume="+w.Resume)
+	}
+
+	if w.ProgressInterval > 0 {
+		args = append(args, "-progress="+w.ProgressInterval.String())
+	}
+
+	if w.Hardlinks {
+		args = append(args, "-hardlinks")
+	}
+
+	if !w.Since.IsZero() {
+		args = append(args, "-since="+w.Since.Format(time.RFC3339Nano))
+	}
+
+	if w.DirComplete {
+		args = append(args, "-dircomplete")
+	}
+
+	if w.Order != OrderLexical {
+		args = append(args, "-order="+w.Order.String())
+	}
+
+	if w.Walkers > 0 {
+		args = append(args, "-walkers="+strconv.Itoa(w.Walkers))
+	}
+
+	if w.ReadSize > 0 {
+		args = append(args, "-readsize="+strconv.Itoa(w.ReadSize))
+	}
+
+	if w.Aggregate {
+		args = append(args, "-aggregate")
+	}
+
+	if len(w.AgeBuckets) > 0 {
+		args = append(args, "-agebuckets="+joinDurations(w.AgeBuckets))
+	}
+
+	for _, path := range w.Skip {
+		args = append(args, "-skip="+path)
+	}
+
+	if w.Shards > 0 {
+		args = append(args, "-shards="+strconv.Itoa(w.Shards))
+	}
+
+	if w.Frontier {
+		args = append(args, "-frontier")
+	}
+
+	if w.SkipRoots {
+		args = append(args, "-skiproots")
+	}
+
+	if w.Stream {
+		args = append(args, "-stream")
+	}
+
+	return args
+}
+
+func joinDurations(durations []time.Duration) string {
+	strs := make([]string, len(durations))
+
+	for n, d := range durations {
+		strs[n] = d.String()
+	}
+
+	return strings.Join(strs, ",")
+}
+
+func appendPatternArgs(args []string, name string, patterns Patterns) []string {
+	for _, pattern := range patterns {
+		args = append(args, "-"+name+"="+pattern)
+	}
+
+	return args
+}
+
+func (w *WalkOptions) dirTimeout() time.Duration {
+	if w == nil {
+		return 0
+	}
+
+	return w.DirTimeout
+}
+
+func (w *WalkOptions) stat() bool {
+	return w != nil && (w.Stat || w.Aggregate || !w.Since.IsZero())
+}
+
+func (w *WalkOptions) maxDepth() int {
+	if w == nil {
+		return 0
+	}
+
+	return w.MaxDepth
+}
+
+func (w *WalkOptions) oneFilesystem() bool {
+	return w != nil && w.OneFilesystem
+}
+
+func (w *WalkOptions) followSymlinks() bool {
+	return w != nil && w.FollowSymlinks
+}
+
+func (w *WalkOptions) resume() string {
+	if w == nil {
+		return ""
+	}
+
+	return w.Resume
+}
+
+func (w *WalkOptions) progressInterval() time.Duration {
+	if w == nil {
+		return 0
+	}
+
+	return w.ProgressInterval
+}
+
+func (w *WalkOptions) hardlinks() bool {
+	return w != nil && w.Hardlinks
+}
+
+func (w *WalkOptions) since() time.Time {
+	if w == nil {
+		return time.Time{}
+	}
+
+	return w.Since
+}
+
+func (w *WalkOptions) dirComplete() bool {
+	return w != nil && w.DirComplete
+}
+
+func (w *WalkOptions) order() WalkOrder {
+	if w == nil {
+		return OrderLexical
+	}
+
+	return w.Order
+}
+
+func (w *WalkOptions) walkers() int {
+	if w == nil || w.Walkers <= 0 {
+		return defaultWalkers
+	}
+
+	return w.Walkers
+}
+
+func (w *WalkOptions) readSize() int {
+	if w == nil || w.ReadSize <= 0 {
+		return os.Getpagesize()
+	}
+
+	return max(w.ReadSize, maxDirentSize)
+}
+
+func (w *WalkOptions) aggregate() bool {
+	return w != nil && w.Aggregate
+}
+
+func (w *WalkOptions) shards() int {
+	if w == nil {
+		return 0
+	}
+
+	return w.Shards
+}
+
+// planOptions returns the options used to read the tree when planning shards,
+// keeping only those that affect which directories are read.
+func (w *WalkOptions) planOptions() *WalkOptions {
+	plan := &WalkOptions{MaxDepth: defaultPlanDepth}
+
+	if w == nil {
+		return plan
+	}
+
+	if w.MaxDepth > 0 {
+		plan.MaxDepth = w.MaxDepth
+	}
+
+	plan.DirTimeout = w.DirTimeout
+	plan.Prune = w.Prune
+	plan.Skip = w.Skip
+	plan.OneFilesystem = w.OneFilesystem
+	plan.FollowSymlinks = w.FollowSymlinks
+	plan.Walkers = w.Walkers
+	plan.ReadSize = w.ReadSize
+
+	return plan
+}
+
+func (w *WalkOptions) skip() Paths {
+	if w == nil {
+		return nil
+	}
+
+	return w.Skip
+}
+
+func (w *WalkOptions) frontier() bool {
+	return w != nil && w.Frontier
+}
+
+func (w *WalkOptions) skipRoots() bool {
+	return w != nil && w.SkipRoots
+}
@@ -26,28 +26,107 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/wtsi-hgi/statter/internal/client"
 )
 
-const walkMode = 2
-
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 
-		os.Exit(1)
+		os.Exit(client.ExitCode(err))
 	}
 }
 
 func run() error {
-	if len(os.Args) == walkMode {
-		client.Walk(os.Args[1])
+	timeout := time.Second
+	rlimits := make(client.Rlimits)
+
+	var (
+		walkOpts client.WalkOptions
+		ping     bool
+	)
+
+	flag.DurationVar(&timeout, "timeout", timeout, "timeout to wait for stat to finish")
+	flag.Var(rlimits, "rlimit", "resource limit to apply, as resource=soft[:hard]; can be repeated")
+	flag.BoolVar(&ping, "ping", false, "answer pings on file descriptor 3")
+	walkOpts.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := rlimits.Apply(); err != nil {
+		return err
+	}
+
+	if flag.NArg() > 0 || walkOpts.Stream {
+		if err := walk(flag.Args(), &walkOpts); err != nil {
+			return err
+		}
+
+		// Closing stdout explicitly ensures that the client sees the end of the
+		// walk, even if a timed out directory read is still stuck in a syscall.
+		return os.Stdout.Close()
+	}
+
+	if ping {
+		go client.ServePings(os.NewFile(client.PingFD, "ping")) //nolint:errcheck
+	}
+
+	return client.Loop(timeout)
+}
+
+// walk walks, or plans shards of, the given roots, or walks the roots read from
+// stdin as they are read when streaming.
+func walk(args []string, opts *client.WalkOptions) error {
+	if opts.Stream {
+		client.WalkStream(os.Stdin, opts)
 
 		return nil
 	}
 
-	return client.Loop()
+	roots, err := readRoots(args, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	if opts.Shards > 0 {
+		client.Plan(roots, opts)
+	} else {
+		client.Walk(roots, opts)
+	}
+
+	return nil
+}
+
+// readRoots returns the given walk roots, replacing any "-" with the roots
+// read, one per line, from the given reader.
+func readRoots(args []string, r io.Reader) ([]string, error) {
+	roots := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if arg != "-" {
+			roots = append(roots, arg)
+
+			continue
+		}
+
+		s := bufio.NewScanner(r)
+
+		for s.Scan() {
+			if line := s.Text(); line != "" {
+				roots = append(roots, line)
+			}
+		}
+
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return roots, nil
 }
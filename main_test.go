@@ -26,12 +26,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -43,6 +50,10 @@ import (
 
 var statterExe string //nolint:gochecknoglobals
 
+func walkErr(_ *client.WalkSummary, err error) error {
+	return err
+}
+
 func TestMain(m *testing.M) {
 	tmp, err := os.MkdirTemp("", "")
 	if err != nil {
@@ -181,6 +192,273 @@ func TestReadlink(t *testing.T) {
 	})
 }
 
+func TestOptions(t *testing.T) {
+	Convey("You can spawn a statter with options", t, func() {
+		tmp := t.TempDir()
+		testPath := filepath.Join(tmp, "aFile")
+
+		So(os.WriteFile(testPath, []byte("some data"), 0600), ShouldBeNil)
+
+		stderr, err := os.Create(filepath.Join(tmp, "stderr"))
+		So(err, ShouldBeNil)
+
+		opts := &client.Options{
+			Timeout: 2 * time.Second,
+			Dir:     tmp,
+			Env:     []string{},
+			Stderr:  stderr,
+			Rlimits: client.Rlimits{syscall.RLIMIT_NOFILE: {Cur: 100, Max: 200}},
+		}
+
+		conn, pid, err := internalclient.CreateStatterWithOptions(statterExe, opts)
+		So(err, ShouldBeNil)
+
+		fi, err := internalclient.Stat(conn, "aFile")
+		So(err, ShouldBeNil)
+		So(fi.Size(), ShouldEqual, 9)
+
+		limits, err := os.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+		So(err, ShouldBeNil)
+		So(string(limits), ShouldContainSubstring, "Max open files            100                  200")
+
+		So(conn.Close(), ShouldBeNil)
+
+		opts.Args = []string{"-unknown"}
+
+		statter, _, _, err := client.CreateStatterWithOptions(statterExe, opts)
+		So(err, ShouldBeNil)
+
+		_, err = statter(testPath)
+		So(err, ShouldWrap, io.EOF)
+
+		var exitErr *client.StatterExitedError
+
+		So(errors.As(err, &exitErr), ShouldBeTrue)
+		So(exitErr.ExitCode, ShouldEqual, 2)
+		So(exitErr.Stderr, ShouldStartWith, "flag provided but not defined: -unknown")
+		So(exitErr.Panicked(), ShouldBeFalse)
+
+		errOutput, err := os.ReadFile(stderr.Name())
+		So(err, ShouldBeNil)
+		So(string(errOutput), ShouldContainSubstring, "flag provided but not defined: -unknown")
+
+		opts.Args = nil
+		opts.Walk.DirTimeout = time.Second
+
+		var found []string
+
+		So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, opts, func(entry *client.Dirent) error {
+			found = append(found, entry.Path)
+
+			return nil
+		}, nil)), ShouldBeNil)
+		So(found, ShouldResemble, []string{tmp + "/", testPath, stderr.Name()})
+	})
+}
+
+func TestStatterExited(t *testing.T) {
+	Convey("A statter that exits reports why", t, func() {
+		tmp := t.TempDir()
+
+		Convey("with a distinct exit code for timeouts", func() {
+			fifo := filepath.Join(tmp, "fifo")
+			So(syscall.Mkfifo(fifo, 0600), ShouldBeNil)
+
+			_, header, _, err := client.CreateStatterWithOptions(statterExe, &client.Options{Timeout: 100 * time.Millisecond})
+			So(err, ShouldBeNil)
+
+			_, err = header(fifo)
+			So(errors.Is(err, client.ErrTimeout), ShouldBeTrue)
+
+			var exitErr *client.StatterExitedError
+
+			So(errors.As(err, &exitErr), ShouldBeTrue)
+			So(exitErr.ExitCode, ShouldEqual, 3)
+		})
+
+		Convey("and is killed if it does not exit after its pipe dies", func() {
+			exe := filepath.Join(tmp, "statter.sh")
+
+			So(os.WriteFile(exe, []byte("#!/bin/sh\necho timeout >&2\nexec >&-\nexec sleep 60\n"), 0700), //nolint:gosec
+				ShouldBeNil)
+
+			statter, _, _, err := client.CreateStatterWithOptions(exe, nil)
+			So(err, ShouldBeNil)
+
+			_, err = statter(tmp)
+			So(errors.Is(err, client.ErrTimeout), ShouldBeFalse)
+
+			var exitErr *client.StatterExitedError
+
+			So(errors.As(err, &exitErr), ShouldBeTrue)
+			So(exitErr.Signal, ShouldEqual, syscall.SIGKILL)
+		})
+	})
+}
+
+func TestPing(t *testing.T) {
+	Convey("You can ping a statter to check that it is responsive", t, func() {
+		c, err := client.Connect(statterExe, nil)
+		So(err, ShouldBeNil)
+
+		Reset(func() { c.Close() })
+
+		So(c.Ping(t.Context()), ShouldBeNil)
+
+		fi, err := c.Stat(statterExe)
+		So(err, ShouldBeNil)
+		So(fi.Name(), ShouldEqual, "statter")
+
+		So(c.Ping(t.Context()), ShouldBeNil)
+
+		Convey("even while a request is stuck", func() {
+			slow, err := client.Connect(statterExe, &client.Options{Timeout: time.Minute})
+			So(err, ShouldBeNil)
+
+			fifo := filepath.Join(t.TempDir(), "fifo")
+			So(syscall.Mkfifo(fifo, 0600), ShouldBeNil)
+
+			headErr := make(chan error, 1)
+
+			go func() {
+				_, err := slow.Head(fifo)
+
+				headErr <- err
+			}()
+
+			time.Sleep(100 * time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+			defer cancel()
+
+			So(slow.Ping(ctx), ShouldBeNil)
+
+			f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+			So(err, ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+			So(<-headErr, ShouldBeNil)
+			So(slow.Close(), ShouldBeNil)
+		})
+
+		Convey("even when the extra arguments end flag parsing", func() {
+			args, err := client.Connect(statterExe, &client.Options{Args: []string{"--"}})
+			So(err, ShouldBeNil)
+
+			So(args.Ping(t.Context()), ShouldBeNil)
+			So(args.Close(), ShouldBeNil)
+		})
+
+		Convey("and a stuck statter will fail to respond in time", func() {
+			exe := filepath.Join(t.TempDir(), "statter.sh")
+
+			So(os.WriteFile(exe, fmt.Appendf(nil, "#!/bin/sh\nkill -STOP $$\nexec %q\n", statterExe), 0700), //nolint:gosec
+				ShouldBeNil)
+
+			stuck, err := client.Connect(exe, nil)
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+			defer cancel()
+
+			So(stuck.Ping(ctx), ShouldEqual, context.DeadlineExceeded)
+
+			_, err = stuck.Stat(statterExe)
+			So(err, ShouldWrap, io.EOF)
+
+			var exitErr *client.StatterExitedError
+
+			So(errors.As(err, &exitErr), ShouldBeTrue)
+			So(exitErr.Signal, ShouldEqual, syscall.SIGKILL)
+		})
+	})
+}
+
+func TestFS(t *testing.T) {
+	Convey("With an FS backed by the statter", t, func() {
+		c, err := client.Connect(statterExe, nil)
+		So(err, ShouldBeNil)
+
+		Reset(func() { c.Close() })
+
+		tmp := t.TempDir()
+		testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		So(os.Symlink("1", filepath.Join(tmp, "link")), ShouldBeNil)
+
+		fsys := client.NewFS(c, tmp)
+
+		Convey("you can walk it", func() {
+			var paths []string
+
+			So(fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+				paths = append(paths, path)
+
+				return err
+			}), ShouldBeNil)
+			So(paths, ShouldResemble, []string{
+				".", "1", "1/1", "1/1.file", "1.file", "2", "2/1", "2/1.file", "2.file", "link",
+			})
+		})
+
+		Convey("you can glob it", func() {
+			matches, err := fs.Glob(fsys, "*/*.file")
+			So(err, ShouldBeNil)
+			So(matches, ShouldResemble, []string{"1/1.file", "2/1.file", "link/1.file"})
+		})
+
+		Convey("you can stat and read links", func() {
+			fi, err := fs.Stat(fsys, "link")
+			So(err, ShouldBeNil)
+			So(fi.IsDir(), ShouldBeTrue)
+			So(fi.Name(), ShouldEqual, "link")
+
+			fi, err = fs.Lstat(fsys, "link")
+			So(err, ShouldBeNil)
+			So(fi.Mode().Type(), ShouldEqual, fs.ModeSymlink)
+
+			target, err := fs.ReadLink(fsys, "link")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, "1")
+
+			_, err = fs.Stat(fsys, "missing")
+			So(err, ShouldWrap, fs.ErrNotExist)
+			So(err.Error(), ShouldEqual, "stat missing: no such file or directory")
+
+			_, err = fs.Stat(fsys, "../escape")
+			So(err, ShouldWrap, fs.ErrInvalid)
+		})
+
+		Convey("you can open directories and read their entries", func() {
+			f, err := fsys.Open("link")
+			So(err, ShouldBeNil)
+
+			d, ok := f.(fs.ReadDirFile)
+			So(ok, ShouldBeTrue)
+
+			entries, err := d.ReadDir(1)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Name(), ShouldEqual, "1")
+			So(entries[0].IsDir(), ShouldBeTrue)
+
+			entries, err = d.ReadDir(5)
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Name(), ShouldEqual, "1.file")
+
+			_, err = d.ReadDir(1)
+			So(err, ShouldEqual, io.EOF)
+
+			fi, err := entries[0].Info()
+			So(err, ShouldBeNil)
+			So(fi.Size(), ShouldEqual, 1)
+
+			_, err = f.Read(make([]byte, 1))
+			So(err, ShouldWrap, errors.ErrUnsupported)
+		})
+	})
+}
+
 func TestWalker(t *testing.T) {
 	Convey("With a test directory to walk", t, func() {
 		tmp := t.TempDir()
@@ -189,7 +467,7 @@ func TestWalker(t *testing.T) {
 		foundPaths := make([]string, 0, len(paths))
 		gotErrors := []string{}
 
-		So(client.WalkPath(statterExe, tmp, func(entry *client.Dirent) error {
+		So(walkErr(client.WalkPath(statterExe, []string{tmp}, func(entry *client.Dirent) error {
 			foundPaths = append(foundPaths, entry.Path)
 
 			return nil
@@ -197,7 +475,7 @@ func TestWalker(t *testing.T) {
 			gotErrors = append(gotErrors, fmt.Sprintf("%s: %s", path, err))
 
 			return nil
-		}), ShouldBeNil)
+		})), ShouldBeNil)
 		So(len(gotErrors), ShouldEqual, 0)
 		So(len(foundPaths), ShouldEqual, len(paths)+1)
 		So(foundPaths[0], ShouldEqual, tmp+"/")
@@ -207,17 +485,17 @@ func TestWalker(t *testing.T) {
 
 		Reset(func() { os.Chmod(filepath.Join(tmp, "1"), 0700) }) //nolint:errcheck
 
-		So(client.WalkPath(statterExe, tmp, func(entry *client.Dirent) error {
+		So(walkErr(client.WalkPath(statterExe, []string{tmp}, func(entry *client.Dirent) error {
 			return nil
 		}, func(path string, err error) error {
 			gotErrors = append(gotErrors, fmt.Sprintf("%s: %s", path, err))
 
 			return nil
-		}), ShouldBeNil)
+		})), ShouldBeNil)
 		So(len(gotErrors), ShouldEqual, 1)
 		So(gotErrors[0], ShouldEqual, tmp+"/1/: permission denied")
 
-		err := client.WalkPath(statterExe, tmp, func(entry *client.Dirent) error {
+		_, err := client.WalkPath(statterExe, []string{tmp}, func(entry *client.Dirent) error {
 			return errors.New("bad!") //nolint:err113
 		}, func(path string, err error) error {
 			return nil
@@ -225,8 +503,1018 @@ func TestWalker(t *testing.T) {
 		So(err, ShouldNotBeNil)
 		So(err.Error(), ShouldEqual, "bad!")
 
-		err = client.WalkPath(statterExe, "", nil, nil)
+		gotErrors = nil
+
+		So(walkErr(client.WalkPath(statterExe, []string{""}, nil, func(path string, err error) error {
+			gotErrors = append(gotErrors, fmt.Sprintf("%s: %s", path, err))
+
+			return nil
+		})), ShouldBeNil)
+		So(gotErrors, ShouldResemble, []string{"./: invalid argument"})
+
+		exe := filepath.Join(t.TempDir(), "statter.sh")
+
+		So(os.WriteFile(exe, []byte("#!/bin/sh\necho broken >&2\nexit 3\n"), 0700), ShouldBeNil) //nolint:gosec
+
+		_, err = client.WalkPathWithOptions(exe, []string{tmp}, &client.Options{Stderr: io.Discard}, nil, nil)
 		So(err, ShouldNotBeNil)
-		So(err.Error(), ShouldEqual, "invalid argument")
+		So(err.Error(), ShouldEqual, "statter exited: exit status 3: broken")
+	})
+}
+
+func TestWalkRoots(t *testing.T) {
+	Convey("With a test directory to walk", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		Convey("You can walk multiple roots in a single walk", func() {
+			var (
+				found   []string
+				markers []string
+			)
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp + "/2", tmp + "/1/1", tmp + "/1", tmp + "/2/"},
+				&client.Options{Handlers: client.WalkHandlers{
+					RootStart: func(root string) error {
+						markers = append(markers, "start "+root)
+
+						return nil
+					},
+					RootEnd: func(root string) error {
+						markers = append(markers, "end "+root)
+
+						return nil
+					},
+				}}, func(entry *client.Dirent) error {
+					found = append(found, entry.Path)
+
+					return nil
+				}, func(path string, err error) error {
+					return err
+				})), ShouldBeNil)
+
+			So(markers, ShouldResemble, []string{
+				"start " + tmp + "/1/", "end " + tmp + "/1/",
+				"start " + tmp + "/2/", "end " + tmp + "/2/",
+			})
+			So(found, ShouldResemble, slices.DeleteFunc(slices.Clone(paths), func(path string) bool {
+				return strings.HasSuffix(path, ".file") && filepath.Dir(path) == tmp
+			}))
+		})
+
+		Convey("A root that can't be walked is reported, and the remaining roots are still walked", func() {
+			var (
+				markers []string
+				errs    []string
+			)
+
+			summary, err := client.WalkPathWithOptions(statterExe, []string{tmp + "/1", tmp + "/missing", tmp + "/2"},
+				&client.Options{Handlers: client.WalkHandlers{
+					RootStart: func(root string) error {
+						markers = append(markers, "start "+root)
+
+						return nil
+					},
+					RootEnd: func(root string) error {
+						markers = append(markers, "end "+root)
+
+						return nil
+					},
+				}}, func(*client.Dirent) error {
+					return nil
+				}, func(path string, err error) error {
+					errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+
+					return nil
+				})
+			So(err, ShouldBeNil)
+			So(markers, ShouldResemble, []string{
+				"start " + tmp + "/1/", "end " + tmp + "/1/",
+				"start " + tmp + "/2/", "end " + tmp + "/2/",
+				"start " + tmp + "/missing/", "end " + tmp + "/missing/",
+			})
+			So(errs, ShouldResemble, []string{tmp + "/missing/: stat " + tmp + "/missing: no such file or directory"})
+			So(summary.Errors, ShouldEqual, 1)
+			So(summary.Dirs, ShouldEqual, 4)
+		})
+
+		Convey("You can read roots from stdin", func() {
+			roots, err := readRoots([]string{tmp + "/1", "-", tmp + "/2"},
+				strings.NewReader(tmp+"/a\n\n"+tmp+"/b\n"))
+			So(err, ShouldBeNil)
+			So(roots, ShouldResemble, []string{tmp + "/1", tmp + "/a", tmp + "/b", tmp + "/2"})
+		})
+
+		Convey("Walking no roots is an error", func() {
+			So(walkErr(client.WalkPath(statterExe, nil, nil, nil)), ShouldEqual, fs.ErrInvalid)
+		})
+	})
+}
+
+func TestWalkCheckpoint(t *testing.T) {
+	Convey("With a walk that emits checkpoints", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		walk := func(opts client.WalkOptions) ([]string, []string) {
+			var found, checkpoints []string
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{
+				Walk: opts,
+				Handlers: client.WalkHandlers{
+					Checkpoint: func(checkpoint string) error {
+						checkpoints = append(checkpoints, checkpoint)
+
+						return nil
+					},
+				},
+			}, func(entry *client.Dirent) error {
+				found = append(found, entry.Path)
+
+				return nil
+			}, func(_ string, err error) error {
+				return err
+			})), ShouldBeNil)
+
+			return found, checkpoints
+		}
+
+		found, checkpoints := walk(client.WalkOptions{CheckpointInterval: time.Nanosecond})
+		So(found, ShouldResemble, append([]string{tmp + "/"}, paths...))
+		So(checkpoints, ShouldResemble, []string{
+			tmp + "/1/1/", tmp + "/1/", tmp + "/2/1/", tmp + "/2/", tmp + "/",
+		})
+
+		Convey("You can resume the walk from a checkpoint", func() {
+			found, _ := walk(client.WalkOptions{Resume: tmp + "/1/"})
+			So(found, ShouldResemble, []string{tmp + "/2.file", tmp + "/2/", tmp + "/2/1.file", tmp + "/2/1/"})
+
+			found, _ = walk(client.WalkOptions{Resume: tmp + "/"})
+			So(found, ShouldBeNil)
+		})
+	})
+}
+
+func TestWalkSummary(t *testing.T) {
+	Convey("A completed walk returns a summary of its totals", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		So(os.Chmod(filepath.Join(tmp, "2"), 0), ShouldBeNil)
+		Reset(func() { os.Chmod(filepath.Join(tmp, "2"), 0700) }) //nolint:errcheck
+
+		summary, err := client.WalkPathWithOptions(statterExe, []string{tmp},
+			&client.Options{Walk: client.WalkOptions{Stat: true}},
+			func(*client.Dirent) error { return nil },
+			func(string, error) error { return nil })
+		So(err, ShouldBeNil)
+		So(summary.Entries, ShouldEqual, len(paths)-1)
+		So(summary.Dirs, ShouldEqual, 4)
+		So(summary.Errors, ShouldEqual, 1)
+		So(summary.Errnos, ShouldResemble, map[syscall.Errno]uint64{syscall.EACCES: 1})
+		So(summary.Bytes, ShouldEqual, 3)
+		So(summary.Elapsed, ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestWalkHardlinks(t *testing.T) {
+	Convey("With a test directory containing hardlinks", t, func() {
+		tmp := t.TempDir()
+
+		So(os.WriteFile(filepath.Join(tmp, "a"), []byte("abcd"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "c"), []byte("ef"), 0600), ShouldBeNil)
+		So(os.Mkdir(filepath.Join(tmp, "d"), 0700), ShouldBeNil)
+		So(os.Link(filepath.Join(tmp, "a"), filepath.Join(tmp, "d", "b")), ShouldBeNil)
+		So(os.Link(filepath.Join(tmp, "a"), filepath.Join(tmp, "z")), ShouldBeNil)
+
+		walk := func(opts client.WalkOptions) (map[string]string, *client.WalkSummary) {
+			links := make(map[string]string)
+
+			summary, err := client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					links[entry.Path] = entry.HardlinkOf
+
+					return nil
+				}, func(_ string, err error) error {
+					return err
+				})
+			So(err, ShouldBeNil)
+
+			return links, summary
+		}
+
+		Convey("Hardlinks are not tracked by default", func() {
+			links, summary := walk(client.WalkOptions{Stat: true})
+			So(links, ShouldResemble, map[string]string{
+				tmp + "/": "", tmp + "/a": "", tmp + "/c": "", tmp + "/d/": "", tmp + "/d/b": "", tmp + "/z": "",
+			})
+			So(summary.Bytes, ShouldEqual, 14)
+		})
+
+		Convey("You can have later paths marked as hardlinks of the first", func() {
+			links, summary := walk(client.WalkOptions{Stat: true, Hardlinks: true})
+			So(links, ShouldResemble, map[string]string{
+				tmp + "/": "", tmp + "/a": "", tmp + "/c": "", tmp + "/d/": "", tmp + "/d/b": tmp + "/a", tmp + "/z": tmp + "/a",
+			})
+			So(summary.Bytes, ShouldEqual, 6)
+
+			links, _ = walk(client.WalkOptions{Hardlinks: true})
+			So(links[tmp+"/z"], ShouldEqual, tmp+"/a")
+		})
+	})
+}
+
+func TestWalkSince(t *testing.T) {
+	Convey("With a test directory that has changed since a given time", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		time.Sleep(20 * time.Millisecond)
+
+		since := time.Now()
+
+		time.Sleep(20 * time.Millisecond)
+
+		So(os.WriteFile(filepath.Join(tmp, "1.file"), []byte("changed"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "2", "new"), nil, 0600), ShouldBeNil)
+		So(os.Chmod(filepath.Join(tmp, "1", "1.file"), 0400), ShouldBeNil)
+
+		walk := func(opts client.WalkOptions) []*client.Dirent {
+			var found []*client.Dirent
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					found = append(found, entry)
+
+					return nil
+				}, func(_ string, err error) error {
+					return err
+				})), ShouldBeNil)
+
+			return found
+		}
+
+		So(len(walk(client.WalkOptions{})), ShouldEqual, len(paths)+2)
+
+		Convey("You can walk only the entries changed since that time", func() {
+			found := walk(client.WalkOptions{Since: since})
+			So(len(found), ShouldEqual, 4)
+
+			So(found[0].Path, ShouldEqual, tmp+"/1.file")
+			So(found[0].DeletionHint, ShouldBeFalse)
+			So(found[0].Size, ShouldEqual, len("changed"))
+			So(found[1].Path, ShouldEqual, tmp+"/1/1.file")
+			So(found[1].Mode, ShouldEqual, 0400)
+			So(found[2].Path, ShouldEqual, tmp+"/2/")
+			So(found[2].DeletionHint, ShouldBeTrue)
+			So(found[3].Path, ShouldEqual, tmp+"/2/new")
+			So(found[3].DeletionHint, ShouldBeFalse)
+		})
+	})
+}
+
+func TestWalkDirComplete(t *testing.T) {
+	Convey("With a test directory to walk", t, func() {
+		tmp := t.TempDir()
+		testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		type dirComplete struct {
+			path    string
+			entries uint64
+		}
+
+		walk := func(opts client.WalkOptions) ([]string, []dirComplete) {
+			var (
+				found     []string
+				completed []dirComplete
+			)
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{
+				Walk: opts,
+				Handlers: client.WalkHandlers{
+					DirComplete: func(path string, entries uint64) error {
+						completed = append(completed, dirComplete{path, entries})
+
+						return nil
+					},
+				},
+			}, func(entry *client.Dirent) error {
+				found = append(found, entry.Path)
+
+				return nil
+			}, func(_ string, err error) error {
+				return err
+			})), ShouldBeNil)
+
+			return found, completed
+		}
+
+		_, completed := walk(client.WalkOptions{})
+		So(completed, ShouldBeEmpty)
+
+		Convey("You can receive a record as each directory is completed", func() {
+			found, completed := walk(client.WalkOptions{DirComplete: true})
+			So(completed, ShouldResemble, []dirComplete{
+				{tmp + "/1/1/", 0},
+				{tmp + "/1/", 2},
+				{tmp + "/2/1/", 0},
+				{tmp + "/2/", 2},
+				{tmp + "/", 4},
+			})
+
+			var total uint64
+
+			for _, c := range completed {
+				total += c.entries
+
+				So(slices.Index(found, c.path), ShouldBeGreaterThan, -1)
+			}
+
+			So(total, ShouldEqual, len(found)-1)
+		})
+
+		Convey("Entry counts only include entries that were output", func() {
+			_, completed := walk(client.WalkOptions{DirComplete: true, Exclude: client.Patterns{"*.file"}})
+			So(completed, ShouldResemble, []dirComplete{
+				{tmp + "/1/1/", 0},
+				{tmp + "/1/", 1},
+				{tmp + "/2/1/", 0},
+				{tmp + "/2/", 1},
+				{tmp + "/", 2},
+			})
+		})
+	})
+}
+
+func TestWalkOrder(t *testing.T) {
+	Convey("With a test directory to walk", t, func() {
+		tmp := t.TempDir()
+		testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		walk := func(opts client.WalkOptions) ([]string, []string, error) {
+			var found, completed []string
+
+			err := walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{
+				Walk: opts,
+				Handlers: client.WalkHandlers{
+					DirComplete: func(path string, _ uint64) error {
+						completed = append(completed, strings.TrimPrefix(path, tmp))
+
+						return nil
+					},
+				},
+			}, func(entry *client.Dirent) error {
+				found = append(found, strings.TrimPrefix(entry.Path, tmp))
+
+				return nil
+			}, func(_ string, err error) error {
+				return err
+			}))
+
+			return found, completed, err
+		}
+
+		Convey("Entries are output in lexical order by default", func() {
+			found, completed, err := walk(client.WalkOptions{DirComplete: true})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{
+				"/", "/1.file", "/1/", "/1/1.file", "/1/1/", "/2.file", "/2/", "/2/1.file", "/2/1/",
+			})
+			So(completed, ShouldResemble, []string{"/1/1/", "/1/", "/2/1/", "/2/", "/"})
+		})
+
+		Convey("You can output entries breadth-first", func() {
+			found, completed, err := walk(client.WalkOptions{DirComplete: true, Order: client.OrderBreadthFirst})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{
+				"/", "/1.file", "/1/", "/2.file", "/2/", "/1/1.file", "/1/1/", "/2/1.file", "/2/1/",
+			})
+			So(completed, ShouldResemble, []string{"/", "/1/", "/2/", "/1/1/", "/2/1/"})
+		})
+
+		Convey("You can output directories first", func() {
+			found, completed, err := walk(client.WalkOptions{DirComplete: true, Order: client.OrderDirsFirst})
+			So(err, ShouldBeNil)
+			So(found, ShouldResemble, []string{
+				"/", "/1/", "/1/1/", "/1/1.file", "/2/", "/2/1/", "/2/1.file", "/1.file", "/2.file",
+			})
+			So(completed, ShouldResemble, []string{"/1/1/", "/1/", "/2/1/", "/2/", "/"})
+		})
+
+		Convey("You can't resume a walk that isn't in lexical order", func() {
+			_, _, err := walk(client.WalkOptions{Order: client.OrderDirsFirst, Resume: tmp + "/1/"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, client.ErrInvalidOrder.Error())
+		})
+	})
+}
+
+func TestWalkAggregate(t *testing.T) {
+	Convey("With a test directory containing files of different ages", t, func() {
+		tmp := t.TempDir()
+		old := time.Now().Add(-100 * 24 * time.Hour)
+
+		So(os.Mkdir(filepath.Join(tmp, "dir"), 0700), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "new"), []byte("abc"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "old"), []byte("abcdef"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "dir", "a"), []byte("a"), 0600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(tmp, "dir", "b"), []byte("bb"), 0600), ShouldBeNil)
+		So(os.Chtimes(filepath.Join(tmp, "old"), old, old), ShouldBeNil)
+
+		allocated := func(paths ...string) uint64 {
+			var total uint64
+
+			for _, path := range paths {
+				fi, err := os.Lstat(filepath.Join(tmp, path))
+				So(err, ShouldBeNil)
+
+				total += uint64(fi.Sys().(*syscall.Stat_t).Blocks) * 512 //nolint:errcheck,forcetypeassert,gosec
+			}
+
+			return total
+		}
+
+		dirFI, err := os.Lstat(filepath.Join(tmp, "dir"))
+		So(err, ShouldBeNil)
+
+		uid, gid := uint32(os.Getuid()), uint32(os.Getgid()) //nolint:gosec
+
+		walk := func(opts client.WalkOptions) ([]*client.DirUsage, int) {
+			var (
+				usage   []*client.DirUsage
+				entries int
+			)
+
+			opts.Aggregate = true
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{
+				Walk: opts,
+				Handlers: client.WalkHandlers{
+					Usage: func(u *client.DirUsage) error {
+						usage = append(usage, u)
+
+						return nil
+					},
+				},
+			}, func(*client.Dirent) error {
+				entries++
+
+				return nil
+			}, func(_ string, err error) error {
+				return err
+			})), ShouldBeNil)
+
+			return usage, entries
+		}
+
+		Convey("You can get usage totals for each directory instead of entries", func() {
+			usage, entries := walk(client.WalkOptions{})
+			So(entries, ShouldEqual, 0)
+			So(usage, ShouldResemble, []*client.DirUsage{
+				{Path: tmp + "/dir/", Usage: []client.Usage{
+					{UID: uid, GID: gid, AgeBucket: 0, Count: 2, Bytes: 3, Allocated: allocated("dir/a", "dir/b")},
+				}},
+				{Path: tmp + "/", Usage: []client.Usage{
+					{UID: uid, GID: gid, AgeBucket: 0, Count: 1, Bytes: 3, Allocated: allocated("new")},
+					{UID: uid, GID: gid, AgeBucket: 2, Count: 1, Bytes: 6, Allocated: allocated("old")},
+					{UID: uid, GID: gid, Type: fs.ModeDir, AgeBucket: 0, Count: 1, Bytes: uint64(dirFI.Size()), //nolint:gosec
+						Allocated: allocated("dir")},
+				}},
+			})
+		})
+
+		Convey("You can set the age buckets", func() {
+			usage, _ := walk(client.WalkOptions{AgeBuckets: []time.Duration{time.Hour, 1000 * time.Hour}})
+			So(len(usage), ShouldEqual, 2)
+			So(usage[1].Usage[0].AgeBucket, ShouldEqual, 0)
+			So(usage[1].Usage[1].AgeBucket, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestWalkShards(t *testing.T) {
+	Convey("With an unbalanced test directory", t, func() {
+		tmp := t.TempDir()
+
+		for dir, files := range map[string]int{
+			"big/a": 20, "big/b": 20, "big/c": 20, "big/d": 20, "big": 3, "small1": 5, "small2": 5,
+		} {
+			So(os.MkdirAll(filepath.Join(tmp, dir), 0700), ShouldBeNil)
+
+			for n := range files {
+				So(os.WriteFile(filepath.Join(tmp, dir, strconv.Itoa(n)), nil, 0600), ShouldBeNil)
+			}
+		}
+
+		walk := func(roots []string, opts client.WalkOptions) []string {
+			var found []string
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, roots, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					found = append(found, entry.Path)
+
+					return nil
+				}, func(_ string, err error) error {
+					return err
+				})), ShouldBeNil)
+
+			return found
+		}
+
+		all := walk([]string{tmp}, client.WalkOptions{})
+
+		Convey("You can skip directories by path", func() {
+			found := walk([]string{tmp}, client.WalkOptions{Skip: client.Paths{tmp + "/big/a", tmp + "/small1/"}})
+			So(len(found), ShouldEqual, len(all)-21-6)
+			So(found, ShouldNotContain, tmp+"/big/a/")
+			So(found, ShouldContain, tmp+"/big/b/")
+		})
+
+		Convey("You can plan balanced shards that cover the whole tree", func() {
+			shards, err := client.PlanShards(statterExe, []string{tmp}, 3, nil)
+			So(err, ShouldBeNil)
+			So(len(shards), ShouldEqual, 3)
+
+			var (
+				total    uint64
+				combined []string
+			)
+
+			for _, shard := range shards {
+				total += shard.Estimate
+
+				So(shard.Estimate, ShouldBeLessThan, len(all)/2)
+
+				found := walk(shard.Roots, client.WalkOptions{Skip: shard.Skip})
+				So(uint64(len(found)), ShouldEqual, shard.Estimate)
+
+				combined = append(combined, found...)
+			}
+
+			So(total, ShouldEqual, len(all))
+
+			slices.Sort(combined)
+			So(combined, ShouldResemble, all)
+		})
+
+		Convey("Planning more shards than there are parts returns fewer shards", func() {
+			So(os.RemoveAll(filepath.Join(tmp, "big")), ShouldBeNil)
+
+			shards, err := client.PlanShards(statterExe, []string{tmp}, 100, nil)
+			So(err, ShouldBeNil)
+			So(len(shards), ShouldEqual, 3)
+			So(shards[0].Roots, ShouldResemble, []string{tmp + "/small1/"})
+			So(shards[1].Roots, ShouldResemble, []string{tmp + "/small2/"})
+			So(shards[2].Roots, ShouldResemble, []string{tmp + "/"})
+			So(shards[2].Skip, ShouldResemble, []string{tmp + "/small1/", tmp + "/small2/"})
+		})
+
+		Convey("Directories at the plan depth are sized by their entries", func() {
+			shards, err := client.PlanShards(statterExe, []string{tmp}, 2,
+				&client.Options{Walk: client.WalkOptions{MaxDepth: 1}})
+			So(err, ShouldBeNil)
+			So(len(shards), ShouldEqual, 2)
+			So(shards[0].Roots, ShouldResemble, []string{tmp + "/"})
+			So(shards[0].Skip, ShouldResemble, []string{tmp + "/small1/", tmp + "/small2/"})
+			So(shards[0].Estimate, ShouldEqual, 9)
+			So(shards[1].Roots, ShouldResemble, []string{tmp + "/small1/", tmp + "/small2/"})
+			So(shards[1].Estimate, ShouldEqual, 12)
+		})
+
+		Convey("Roots that can't be walked are left out of the plan", func() {
+			shards, err := client.PlanShards(statterExe, []string{tmp + "/small1", tmp + "/missing"}, 2, nil)
+			So(err, ShouldBeNil)
+			So(len(shards), ShouldEqual, 1)
+			So(shards[0].Roots, ShouldResemble, []string{tmp + "/small1/"})
+		})
+
+		Convey("You can't plan zero shards", func() {
+			_, err := client.PlanShards(statterExe, []string{tmp}, 0, nil)
+			So(err, ShouldEqual, fs.ErrInvalid)
+		})
+	})
+}
+
+func TestParallelWalk(t *testing.T) {
+	Convey("With a deep test directory", t, func() {
+		tmp := t.TempDir()
+		testhelper.FillDirWithFiles(t, tmp, 5, nil)
+
+		type walkResult struct {
+			found   []string
+			errs    []string
+			usage   []string
+			summary *client.WalkSummary
+		}
+
+		walk := func(parallel bool, opts client.WalkOptions) walkResult {
+			var (
+				r   walkResult
+				err error
+			)
+
+			o := &client.Options{Walk: opts, Handlers: client.WalkHandlers{
+				Usage: func(u *client.DirUsage) error {
+					r.usage = append(r.usage, fmt.Sprint(*u))
+
+					return nil
+				},
+			}}
+			cb := func(entry *client.Dirent) error {
+				r.found = append(r.found, entry.Path)
+
+				return nil
+			}
+			errCB := func(path string, err error) error {
+				r.errs = append(r.errs, fmt.Sprintf("%s: %s", path, err))
+
+				return nil
+			}
+
+			if parallel {
+				r.summary, err = client.ParallelWalk(statterExe, []string{tmp}, 3, o, cb, errCB)
+			} else {
+				r.summary, err = client.WalkPathWithOptions(statterExe, []string{tmp}, o, cb, errCB)
+			}
+
+			So(err, ShouldBeNil)
+
+			slices.Sort(r.found)
+			slices.Sort(r.usage)
+
+			r.summary.Elapsed = 0
+
+			return r
+		}
+
+		Convey("A parallel walk outputs the same entries as a single walk", func() {
+			So(os.Chmod(filepath.Join(tmp, "3", "2", "1"), 0), ShouldBeNil)
+			Reset(func() { os.Chmod(filepath.Join(tmp, "3", "2", "1"), 0700) }) //nolint:errcheck
+
+			serial := walk(false, client.WalkOptions{})
+			parallel := walk(true, client.WalkOptions{})
+
+			So(len(parallel.found), ShouldBeGreaterThan, 100)
+			So(parallel, ShouldResemble, serial)
+		})
+
+		Convey("A parallel walk respects the maximum depth", func() {
+			So(walk(true, client.WalkOptions{MaxDepth: 3}), ShouldResemble, walk(false, client.WalkOptions{MaxDepth: 3}))
+		})
+
+		Convey("A parallel walk can aggregate usage", func() {
+			parallel := walk(true, client.WalkOptions{Aggregate: true})
+			So(parallel.found, ShouldBeEmpty)
+			So(parallel.usage, ShouldNotBeEmpty)
+			So(parallel, ShouldResemble, walk(false, client.WalkOptions{Aggregate: true}))
+		})
+
+		Convey("A directory that vanishes before it is walked is reported as an error for that path", func() {
+			gone := filepath.Join(tmp, "3", "2")
+
+			var (
+				found []string
+				errs  []string
+			)
+
+			// With one worker, the directories handed back are not walked until
+			// the walk that found them has finished.
+			_, err := client.ParallelWalk(statterExe, []string{tmp}, 1, nil, func(entry *client.Dirent) error {
+				found = append(found, entry.Path)
+
+				if entry.Path == gone+"/" {
+					return os.RemoveAll(gone)
+				}
+
+				return nil
+			}, func(path string, err error) error {
+				errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(errs, ShouldResemble, []string{gone + "/: stat " + gone + "/: no such file or directory"})
+			So(found, ShouldContain, gone+"/")
+			So(found, ShouldContain, filepath.Join(tmp, "4", "3", "2", "1")+"/")
+			So(found, ShouldNotContain, filepath.Join(gone, "1")+"/")
+		})
+
+		Convey("A parallel walk stops when a callback returns an error", func() {
+			stop := errors.New("stop") //nolint:err113
+
+			_, err := client.ParallelWalk(statterExe, []string{tmp}, 3, nil, func(*client.Dirent) error {
+				return stop
+			}, func(string, error) error { return nil })
+			So(err, ShouldWrap, stop)
+		})
+
+		Convey("A parallel walk keeps one statter running per worker", func() {
+			log := filepath.Join(t.TempDir(), "starts")
+			exe := filepath.Join(t.TempDir(), "statter")
+			So(os.WriteFile(exe, fmt.Appendf(nil, "#!/bin/sh\necho >> %q\nexec %q \"$@\"\n", log, statterExe), //nolint:gosec
+				0700), ShouldBeNil)
+
+			_, err := client.ParallelWalk(exe, []string{tmp}, 3, nil, func(*client.Dirent) error {
+				return nil
+			}, func(string, error) error { return nil })
+			So(err, ShouldBeNil)
+
+			starts, err := os.ReadFile(log)
+			So(err, ShouldBeNil)
+			So(bytes.Count(starts, []byte("\n")), ShouldBeBetweenOrEqual, 2, 4)
+		})
+
+		Convey("A parallel walk can hand back directories with newlines in their names", func() {
+			So(os.MkdirAll(filepath.Join(tmp, "4", "3", "a\nb", "c", "d"), 0700), ShouldBeNil)
+
+			parallel := walk(true, client.WalkOptions{})
+			So(parallel.found, ShouldContain, filepath.Join(tmp, "4", "3", "a\nb", "c", "d")+"/")
+			So(parallel, ShouldResemble, walk(false, client.WalkOptions{}))
+		})
+
+		Convey("A parallel walk needs roots and workers, and can't follow symlinks", func() {
+			_, err := client.ParallelWalk(statterExe, nil, 3, nil, nil, nil)
+			So(err, ShouldEqual, fs.ErrInvalid)
+
+			_, err = client.ParallelWalk(statterExe, []string{tmp}, 0, nil, nil, nil)
+			So(err, ShouldEqual, fs.ErrInvalid)
+
+			_, err = client.ParallelWalk(statterExe, []string{tmp}, 3,
+				&client.Options{Walk: client.WalkOptions{FollowSymlinks: true}}, nil, nil)
+			So(err, ShouldEqual, fs.ErrInvalid)
+		})
+	})
+}
+
+func TestWalkFilter(t *testing.T) {
+	Convey("With a test directory to walk", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 3, nil)
+
+		walk := func(opts client.WalkOptions) []string {
+			var found []string
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					found = append(found, entry.Path)
+
+					return nil
+				}, func(path string, err error) error {
+					return err
+				})), ShouldBeNil)
+
+			return found
+		}
+
+		expected := func(keep func(rel string) bool) []string {
+			exp := []string{tmp + "/"}
+
+			for _, path := range paths {
+				if keep(strings.TrimPrefix(path, tmp+"/")) {
+					exp = append(exp, path)
+				}
+			}
+
+			return exp
+		}
+
+		Convey("You can limit the depth of the walk", func() {
+			So(walk(client.WalkOptions{MaxDepth: 2}), ShouldResemble, expected(func(rel string) bool {
+				return strings.Count(strings.TrimSuffix(rel, "/"), "/") < 2
+			}))
+		})
+
+		Convey("You can prune directories", func() {
+			So(walk(client.WalkOptions{Prune: client.Patterns{"2"}}), ShouldResemble,
+				expected(func(rel string) bool {
+					return !strings.HasPrefix(rel, "2/") && !strings.Contains(rel, "/2/")
+				}))
+		})
+
+		Convey("You can include only matching entries", func() {
+			So(walk(client.WalkOptions{Include: client.Patterns{"*.file"}}), ShouldResemble,
+				expected(func(rel string) bool {
+					return strings.HasSuffix(rel, ".file")
+				}))
+		})
+
+		Convey("You can exclude matching entries with a regular expression", func() {
+			So(walk(client.WalkOptions{Exclude: client.Patterns{"re:^1"}}), ShouldResemble,
+				expected(func(rel string) bool {
+					return !strings.HasPrefix(filepath.Base(rel), "1")
+				}))
+		})
+
+		Convey("Invalid patterns are rejected", func() {
+			var p client.Patterns
+
+			So(p.Set("re:("), ShouldWrap, client.ErrInvalidPattern)
+			So(p.Set("["), ShouldWrap, client.ErrInvalidPattern)
+			So(p.Set("*.txt"), ShouldBeNil)
+			So(p, ShouldResemble, client.Patterns{"*.txt"})
+		})
+	})
+}
+
+func TestWalkOneFilesystem(t *testing.T) {
+	Convey("You can stop the walk from crossing filesystems", t, func() {
+		walkRoot := func(opts client.WalkOptions) map[string]*client.Dirent {
+			found := make(map[string]*client.Dirent)
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{"/"}, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					found[entry.Path] = entry
+
+					return nil
+				}, func(string, error) error {
+					return nil
+				})), ShouldBeNil)
+
+			return found
+		}
+
+		found := walkRoot(client.WalkOptions{MaxDepth: 2})
+		So(found["/proc/"], ShouldNotBeNil)
+		So(found["/proc/"].MountBoundary, ShouldBeFalse)
+		So(found["/proc/self"], ShouldNotBeNil)
+
+		found = walkRoot(client.WalkOptions{MaxDepth: 2, OneFilesystem: true})
+		So(found["/proc/"], ShouldNotBeNil)
+		So(found["/proc/"].MountBoundary, ShouldBeTrue)
+		So(found["/proc/self"], ShouldBeNil)
+	})
+}
+
+func TestWalkFollowSymlinks(t *testing.T) {
+	Convey("With a test directory containing symlinks to directories", t, func() {
+		tmp := t.TempDir()
+		ext := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 2, nil)
+
+		So(os.WriteFile(filepath.Join(ext, "a"), nil, 0600), ShouldBeNil)
+		So(os.Symlink(tmp, filepath.Join(tmp, "loop")), ShouldBeNil)
+		So(os.Symlink(filepath.Join(tmp, "2"), filepath.Join(tmp, "dup")), ShouldBeNil)
+		So(os.Symlink(ext, filepath.Join(tmp, "ext")), ShouldBeNil)
+		So(os.Symlink(ext, filepath.Join(tmp, "ext2")), ShouldBeNil)
+
+		walk := func(opts client.WalkOptions) (map[string]fs.FileMode, []string) {
+			found := make(map[string]fs.FileMode)
+			errs := []string{}
+
+			So(walkErr(client.WalkPathWithOptions(statterExe, []string{tmp}, &client.Options{Walk: opts},
+				func(entry *client.Dirent) error {
+					found[entry.Path] = entry.Mode
+
+					return nil
+				}, func(path string, err error) error {
+					errs = append(errs, fmt.Sprintf("%s: %s", path, err))
+
+					return nil
+				})), ShouldBeNil)
+
+			return found, errs
+		}
+
+		Convey("By default, symlinks are not followed", func() {
+			found, errs := walk(client.WalkOptions{})
+			So(errs, ShouldBeEmpty)
+			So(len(found), ShouldEqual, len(paths)+5)
+			So(found[tmp+"/ext"], ShouldEqual, fs.ModeSymlink)
+		})
+
+		Convey("You can follow symlinks, with loops and duplicates reported as errors", func() {
+			found, errs := walk(client.WalkOptions{FollowSymlinks: true})
+			So(errs, ShouldResemble, []string{
+				tmp + "/dup: too many levels of symbolic links",
+				tmp + "/ext2: too many levels of symbolic links",
+				tmp + "/loop: too many levels of symbolic links",
+			})
+			So(len(found), ShouldEqual, len(paths)+6)
+			So(found[tmp+"/ext/"], ShouldEqual, fs.ModeDir)
+			So(found[tmp+"/ext/a"], ShouldEqual, 0)
+			So(found[tmp+"/ext2"], ShouldEqual, fs.ModeSymlink)
+			So(found[tmp+"/dup"], ShouldEqual, fs.ModeSymlink)
+			So(found[tmp+"/loop"], ShouldEqual, fs.ModeSymlink)
+		})
+	})
+}
+
+func TestMountBreaker(t *testing.T) {
+	Convey("With a statter that dies while the mount is unresponsive", t, func() {
+		tmp := t.TempDir()
+		dead := filepath.Join(tmp, "dead")
+		exe := filepath.Join(tmp, "statter.sh")
+
+		So(os.WriteFile(dead, nil, 0600), ShouldBeNil)
+		script := fmt.Sprintf("#!/bin/sh\n[ -e %q ] && echo timeout >&2 && exit 3\nexec %q\n", dead, statterExe)
+
+		So(os.WriteFile(exe, []byte(script), 0700), ShouldBeNil) //nolint:gosec
+
+		b, err := client.NewMountBreaker(exe, nil, 2, 100*time.Millisecond)
+		So(err, ShouldBeNil)
+
+		Reset(func() { b.Close() })
+
+		Convey("requests fail with timeouts until the breaker opens, and succeed once the mount recovers", func() {
+			_, err = b.Stat(tmp)
+			So(errors.Is(err, client.ErrTimeout), ShouldBeTrue)
+
+			_, err = b.Stat(tmp)
+			So(errors.Is(err, client.ErrTimeout), ShouldBeTrue)
+
+			_, err = b.Stat(tmp)
+			So(errors.Is(err, client.ErrMountUnresponsive), ShouldBeTrue)
+
+			_, err = b.Head(dead)
+			So(errors.Is(err, client.ErrMountUnresponsive), ShouldBeTrue)
+
+			So(os.Remove(dead), ShouldBeNil)
+
+			time.Sleep(time.Second)
+
+			fi, err := b.Stat(tmp)
+			So(err, ShouldBeNil)
+			So(fi.IsDir(), ShouldBeTrue)
+		})
+
+		Convey("requests made after it is closed fail without starting a statter", func() {
+			So(os.Remove(dead), ShouldBeNil)
+
+			_, err = b.Stat(tmp)
+			So(err, ShouldBeNil)
+
+			So(b.Close(), ShouldBeNil)
+
+			_, err = b.Stat(tmp)
+			So(errors.Is(err, fs.ErrClosed), ShouldBeTrue)
+		})
+	})
+}
+
+func TestWalkIter(t *testing.T) {
+	Convey("With a test directory to walk", t, func() {
+		tmp := t.TempDir()
+		paths := testhelper.FillDirWithFiles(t, tmp, 3, nil)
+
+		So(os.Chmod(filepath.Join(tmp, "2"), 0), ShouldBeNil)
+
+		Reset(func() { os.Chmod(filepath.Join(tmp, "2"), 0700) }) //nolint:errcheck
+
+		Convey("you can range over the entries and errors", func() {
+			var (
+				found []string
+				errs  []error
+			)
+
+			for entry, err := range client.Walk(statterExe, tmp) {
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					found = append(found, entry.Path)
+				}
+			}
+
+			So(found[0], ShouldEqual, tmp+"/")
+			So(found[1:], ShouldResemble, slices.DeleteFunc(paths, func(path string) bool {
+				return strings.HasPrefix(path, tmp+"/2/") && path != tmp+"/2/"
+			}))
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0], ShouldWrap, fs.ErrPermission)
+			So(errs[0].Error(), ShouldEqual, "walk "+tmp+"/2/: permission denied")
+		})
+
+		Convey("you can stop early", func() {
+			var found []string
+
+			for entry, err := range client.Walk(statterExe, tmp) {
+				if err != nil {
+					continue
+				}
+
+				found = append(found, entry.Path)
+
+				if len(found) == 2 {
+					break
+				}
+			}
+
+			So(found, ShouldResemble, []string{tmp + "/", paths[0]})
+		})
+
+		Convey("fatal errors end the iteration", func() {
+			var errs []error
+
+			for entry, err := range client.WalkWithOptions(statterExe, tmp, &client.Options{Walk: client.WalkOptions{
+				Resume: tmp + "/1/",
+				Order:  client.OrderBreadthFirst,
+			}}) {
+				So(entry, ShouldBeNil)
+
+				errs = append(errs, err)
+			}
+
+			So(len(errs), ShouldEqual, 1)
+			So(errs[0].Error(), ShouldContainSubstring, client.ErrInvalidOrder.Error())
+		})
 	})
 }